@@ -0,0 +1,192 @@
+// Copyright 2026 Fortio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !no_http && !no_net
+
+// Apache/NCSA style access log for LogAndCall.
+
+package log // import "fortio.org/log"
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// AccessLogCommon is the NCSA common log format.
+	AccessLogCommon = `%h %l %u %t "%r" %>s %b`
+	// AccessLogCombined is the Apache combined log format (the default AccessLogFormat).
+	AccessLogCombined = AccessLogCommon + ` "%{Referer}i" "%{User-agent}i"`
+)
+
+// accessLogMutex serializes the access log writes (which can be to a different writer than the regular log).
+var accessLogMutex sync.Mutex
+
+// accessLogEscape escapes quotes, backslashes and control characters (as \xhh, like Apache does)
+// so values can't break the line format or inject lines. Empty values are logged as "-".
+func accessLogEscape(s string) string {
+	if s == "" {
+		return "-"
+	}
+	var buf strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			buf.WriteByte('\\')
+			buf.WriteByte(c)
+		case c < 0x20 || c == 0x7f:
+			buf.WriteString(`\x`)
+			buf.WriteByte(hexDigits[c>>4])
+			buf.WriteByte(hexDigits[c&0xF])
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	return buf.String()
+}
+
+// accessLogHeader returns the (redacted if sensitive) value of the header.
+func accessLogHeader(r *Redactor, name string, headers http.Header) string {
+	v := strings.Join(headers.Values(name), ",")
+	if v != "" && r.IsSensitiveKey(name) {
+		v = r.RedactKeyValue(v)
+	}
+	return accessLogEscape(v)
+}
+
+// accessLogLine formats the access log line for the request and its recorded response per format which
+// supports the following Apache directives: %h (remote host), %l (always -), %u (user), %t (start time),
+// %r (request line), %s and %>s (status), %b (size, - if 0), %B (size), %D (duration in microseconds),
+// %T (duration in seconds), %m (method), %U (path), %q (query string), %H (protocol), %v (host),
+// %{Name}i (request header), %{Name}o (response header) and %%. Other characters are copied as is.
+func (opts *HTTPLogOptions) accessLogLine(format string, r *http.Request, rr *ResponseRecorder) string {
+	redactor := GetRedactor()
+	status := rr.StatusCode
+	switch {
+	case status == 0:
+		status = http.StatusOK // what net/http sends when nothing was written.
+	case status < 0:
+		status = -status // panic marker.
+	}
+	duration := time.Since(rr.startTime)
+	var buf strings.Builder
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		if c != '%' || i+1 == len(format) {
+			buf.WriteByte(c)
+			continue
+		}
+		i++
+		if format[i] == '>' && i+1 < len(format) { // %>s final status, same as %s for us.
+			i++
+		}
+		switch format[i] {
+		case '%':
+			buf.WriteByte('%')
+		case 'h':
+			host, _, err := net.SplitHostPort(r.RemoteAddr)
+			if err != nil {
+				host = r.RemoteAddr
+			}
+			buf.WriteString(accessLogEscape(host))
+		case 'l':
+			buf.WriteByte('-')
+		case 'u':
+			user := ""
+			if r.URL != nil && r.URL.User != nil {
+				user = r.URL.User.Username()
+			} else if u, _, ok := r.BasicAuth(); ok {
+				user = u
+			}
+			buf.WriteString(accessLogEscape(user))
+		case 't':
+			buf.WriteString(rr.startTime.Format("[02/Jan/2006:15:04:05 -0700]"))
+		case 'r':
+			uri := ""
+			if r.URL != nil {
+				uri = redactQuery(r.URL, opts.RedactQueryParams)
+			}
+			buf.WriteString(accessLogEscape(r.Method + " " + uri + " " + r.Proto))
+		case 's':
+			buf.WriteString(strconv.Itoa(status))
+		case 'b':
+			if rr.ContentLength == 0 {
+				buf.WriteByte('-')
+			} else {
+				buf.WriteString(strconv.FormatInt(rr.ContentLength, 10))
+			}
+		case 'B':
+			buf.WriteString(strconv.FormatInt(rr.ContentLength, 10))
+		case 'D':
+			buf.WriteString(strconv.FormatInt(duration.Microseconds(), 10))
+		case 'T':
+			buf.WriteString(strconv.FormatInt(int64(duration/time.Second), 10))
+		case 'm':
+			buf.WriteString(accessLogEscape(r.Method))
+		case 'U':
+			path := ""
+			if r.URL != nil {
+				path = r.URL.EscapedPath()
+			}
+			buf.WriteString(accessLogEscape(path))
+		case 'q':
+			if r.URL != nil && r.URL.RawQuery != "" {
+				u := url.URL{RawQuery: r.URL.RawQuery}
+				buf.WriteString(accessLogEscape(redactQuery(&u, opts.RedactQueryParams)))
+			}
+		case 'H':
+			buf.WriteString(accessLogEscape(r.Proto))
+		case 'v':
+			buf.WriteString(accessLogEscape(r.Host))
+		case '{':
+			end := strings.IndexByte(format[i:], '}')
+			if end < 0 || i+end+1 >= len(format) {
+				buf.WriteString(format[i-1:])
+				return buf.String()
+			}
+			name := format[i+1 : i+end]
+			i += end + 1
+			switch format[i] {
+			case 'i':
+				buf.WriteString(accessLogHeader(redactor, name, r.Header))
+			case 'o':
+				buf.WriteString(accessLogHeader(redactor, name, rr.Header()))
+			default:
+				buf.WriteString(format[i-end-2 : i+1])
+			}
+		default:
+			buf.WriteByte('%')
+			buf.WriteByte(format[i])
+		}
+	}
+	return buf.String()
+}
+
+// writeAccessLog writes the access log line to opts.AccessLog.
+func (opts *HTTPLogOptions) writeAccessLog(r *http.Request, rr *ResponseRecorder) {
+	format := opts.AccessLogFormat
+	if format == "" {
+		format = AccessLogCombined
+	}
+	line := opts.accessLogLine(format, r, rr) + "\n"
+	accessLogMutex.Lock()
+	_, _ = opts.AccessLog.Write([]byte(line))
+	accessLogMutex.Unlock()
+}
@@ -0,0 +1,73 @@
+// Copyright 2026 Fortio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !no_http && !no_net
+
+package log // import "fortio.org/log"
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"testing"
+)
+
+func TestAccessLog(t *testing.T) {
+	SetLogLevelQuiet(Info)
+	var logBuf bytes.Buffer
+	SetOutput(&logBuf)
+	var access bytes.Buffer
+	opts := &HTTPLogOptions{AccessLog: &access, RedactQueryParams: []string{"token"}}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/empty" {
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("hello"))
+	}
+	r := httptest.NewRequest(http.MethodGet, "/tea?a=1&token=secret", nil)
+	r.RemoteAddr = "10.1.2.3:4567"
+	r.Header.Set("Referer", "http://example.com/\"quoted\"")
+	r.Header.Set("User-Agent", "ua\nfake line")
+	r.SetBasicAuth("bob", "pass")
+	LogAndCallWithOptions(opts, "access", handler).ServeHTTP(httptest.NewRecorder(), r)
+	opts.AccessLogFormat = AccessLogCommon
+	r = httptest.NewRequest(http.MethodPost, "/empty", nil)
+	LogAndCallWithOptions(opts, "access", handler).ServeHTTP(httptest.NewRecorder(), r)
+	opts.AccessLogFormat = `%m %U %q %H %v %>s %B %D %T %{Content-Type}o %{Authorization}i %{X}x 100%% %z %`
+	r = &http.Request{Method: "PUT", URL: &url.URL{Path: "/p", RawQuery: "token=x&b=2"}, Proto: "HTTP/1.0", Host: "h",
+		Header: http.Header{"Authorization": []string{"Bearer abc"}}}
+	SetRedactor(DefaultRedactor())
+	LogAndCallWithOptions(opts, "access", handler).ServeHTTP(httptest.NewRecorder(), r)
+	SetRedactor(nil)
+	opts.AccessLogFormat = `%{unterminated`
+	LogAndCallWithOptions(opts, "access", handler).ServeHTTP(httptest.NewRecorder(), r)
+	if logBuf.Len() != 0 {
+		t.Errorf("expected no structured log when access log is set, got %s", logBuf.String())
+	}
+	actual := regexp.MustCompile(`\[\d\d/\w+/\d{4}:\d\d:\d\d:\d\d [-+]\d{4}\]`).ReplaceAllString(access.String(), "[date]")
+	//nolint: lll // long lines in expected.
+	expected := `10.1.2.3 - bob [date] "GET /tea?a=1&token=[REDACTED] HTTP/1.1" 418 5 "http://example.com/\"quoted\"" "ua\x0afake line"
+192.0.2.1 - - [date] "POST /empty HTTP/1.1" 200 -
+PUT /p ?token=[REDACTED]&b=2 HTTP/1.0 h 418 5 D 0 text/plain [REDACTED] %{X}x 100% %z %
+%{unterminated
+`
+	actual = regexp.MustCompile(`418 5 \d+ 0`).ReplaceAllString(actual, "418 5 D 0")
+	if actual != expected {
+		t.Errorf("unexpected:\n%s\nvs:\n%s\n", actual, expected)
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright 2026 Fortio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Additional typed attribute constructors, beyond the basic slog style ones in logger.go.
+// These don't depend on encoding/json so they produce the same output in both json and no_json builds.
+
+package log // import "fortio.org/log"
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Values of Config.DurationFormat.
+const (
+	DurationSeconds = "seconds" // float seconds, e.g 1.5 (default)
+	DurationMillis  = "ms"      // float milliseconds, e.g 1500
+	DurationString  = "string"  // go's time.Duration.String(), e.g "1.5s"
+)
+
+// textValuer is implemented by values that have a different, more human friendly,
+// representation in text and color modes than in JSON.
+type textValuer interface {
+	textString() string
+}
+
+// textValue returns the text/color mode representation of the value.
+func (v *KeyVal) textValue() string {
+	if tv, ok := v.Value.(textValuer); ok {
+		return tv.textString()
+	}
+	return v.StringValue()
+}
+
+type durationValue time.Duration
+
+func (d durationValue) String() string {
+	switch Config.DurationFormat {
+	case DurationMillis:
+		return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', -1, 64)
+	case DurationString:
+		return "\"" + time.Duration(d).String() + "\""
+	default:
+		return strconv.FormatFloat(time.Duration(d).Seconds(), 'f', -1, 64)
+	}
+}
+
+func (d durationValue) textString() string {
+	return time.Duration(d).String()
+}
+
+// Duration logs a duration, in JSON the format depends on Config.DurationFormat (seconds
+// as float by default). In text and color modes it's always the human readable time.Duration string.
+func Duration(key string, value time.Duration) KeyVal {
+	return KeyVal{Key: key, Value: durationValue(value)}
+}
+
+type timeValue time.Time
+
+func (t timeValue) String() string {
+	// Same as the "ts" field of JSON entries, see jsonTimestamp().
+	return fmt.Sprintf("%.6f", TimeToTS(time.Time(t)))
+}
+
+func (t timeValue) textString() string {
+	return time.Time(t).Format("2006-01-02T15:04:05.000Z07:00")
+}
+
+// Time logs a time, in JSON the same way as the entries' timestamps (float seconds since epoch,
+// at microsecond resolution, see [TimeToTS]). In text and color mode as RFC3339 with milliseconds.
+func Time(key string, value time.Time) KeyVal {
+	return KeyVal{Key: key, Value: timeValue(value)}
+}
+
+// Err logs an error under the "err" key. Nil errors are logged as null.
+// Like for Any(), errors implementing json.Marshaler are serialized as JSON (when not using no_json).
+func Err(err error) KeyVal {
+	return Any("err", err)
+}
+
+type stringerValue struct {
+	s fmt.Stringer
+}
+
+func isNil(v any) bool {
+	if v == nil {
+		return true
+	}
+	val := reflect.ValueOf(v)
+	switch val.Kind() { //nolint:exhaustive // only nil-able kinds matter.
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+		return val.IsNil()
+	default:
+		return false
+	}
+}
+
+func (v stringerValue) String() string {
+	if isNil(v.s) {
+		return nullString
+	}
+	return jsonString(v.s.String())
+}
+
+// Stringer logs the String() of value (only called if the entry is emitted). Nil is logged as null.
+func Stringer(key string, value fmt.Stringer) KeyVal {
+	return KeyVal{Key: key, Value: stringerValue{value}}
+}
+
+type hexValue []byte
+
+func (b hexValue) String() string {
+	if b == nil {
+		return nullString
+	}
+	return "\"" + hex.EncodeToString(b) + "\""
+}
+
+// Hex logs bytes as a lowercase hexadecimal string. Nil is logged as null.
+func Hex(key string, value []byte) KeyVal {
+	return KeyVal{Key: key, Value: hexValue(value)}
+}
+
+type base64Value []byte
+
+func (b base64Value) String() string {
+	if b == nil {
+		return nullString
+	}
+	return "\"" + base64.StdEncoding.EncodeToString(b) + "\""
+}
+
+// Base64 logs bytes as a (standard, padded) base64 string. Nil is logged as null.
+func Base64(key string, value []byte) KeyVal {
+	return KeyVal{Key: key, Value: base64Value(value)}
+}
+
+// Uint64 logs an unsigned 64 bits integer as a number (e.g. ids and counters above MaxInt64).
+func Uint64(key string, value uint64) KeyVal {
+	return Any(key, value)
+}
+
+type groupValue []KeyVal
+
+func (g groupValue) String() string {
+	return g.encode(nil, "")
+}
+
+// encode returns the JSON object for the group, redacted (if r isn't nil) using
+// the dotted keys (prefixed by keyPrefix) for sensitive keys matching.
+func (g groupValue) encode(r *Redactor, keyPrefix string) string {
+	var buf strings.Builder
+	buf.WriteString("{")
+	for i := range g {
+		if i != 0 {
+			buf.WriteString(",")
+		}
+		buf.WriteString(jsonString(g[i].Key))
+		buf.WriteString(":")
+		buf.WriteString(redactedJSONValue(r, keyPrefix, &g[i]))
+	}
+	buf.WriteString("}")
+	return buf.String()
+}
+
+// redactedJSONValue returns the JSON value of attr, redacted if r isn't nil.
+func redactedJSONValue(r *Redactor, keyPrefix string, attr *KeyVal) string {
+	if r == nil {
+		return attr.StringValue()
+	}
+	key := keyPrefix + attr.Key
+	if sub, isGroup := attr.Value.(groupValue); isGroup && !r.IsSensitiveKey(key) {
+		return sub.encode(r, key+".")
+	}
+	return r.redactValue(key, attr.StringValue())
+}
+
+// Group returns an attribute nesting the given attributes under name. In JSON it is an object,
+// e.g. log.Group("http", log.Str("method", "GET"), log.Int("status", 200)) produces
+// "http":{"method":"GET","status":200}. In text and color modes the keys are flattened with
+// dotted prefixes instead: http.method="GET", http.status=200. Groups can be nested.
+func Group(name string, attrs ...KeyVal) KeyVal {
+	return KeyVal{Key: name, Value: groupValue(attrs)}
+}
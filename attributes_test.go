@@ -0,0 +1,169 @@
+// Copyright 2026 Fortio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log // import "fortio.org/log"
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestDuration(t *testing.T) {
+	d := 1500 * time.Millisecond
+	for _, tst := range []struct {
+		format   string
+		expected string
+	}{
+		{"", "1.5"},
+		{DurationSeconds, "1.5"},
+		{DurationMillis, "1500"},
+		{DurationString, `"1.5s"`},
+	} {
+		Config.DurationFormat = tst.format
+		kv := Duration("took", d)
+		if kvStr := kv.StringValue(); kvStr != tst.expected {
+			t.Errorf("for %q unexpected:\n%s\nvs:\n%s\n", tst.format, kvStr, tst.expected)
+		}
+		if txt := kv.textValue(); txt != "1.5s" {
+			t.Errorf("unexpected text value %q", txt)
+		}
+	}
+	Config.DurationFormat = DurationSeconds
+}
+
+func TestTime(t *testing.T) {
+	now := time.Date(2024, 2, 3, 4, 5, 6, 789123456, time.UTC)
+	kv := Time("when", now)
+	expected := "1706933106.789123"
+	if kvStr := kv.StringValue(); kvStr != expected {
+		t.Errorf("unexpected:\n%s\nvs:\n%s\n", kvStr, expected)
+	}
+	expected = "2024-02-03T04:05:06.789Z"
+	if txt := kv.textValue(); txt != expected {
+		t.Errorf("unexpected:\n%s\nvs:\n%s\n", txt, expected)
+	}
+}
+
+func TestErrStringerBytes(t *testing.T) {
+	var err error
+	kv := Err(err)
+	if kv.Key != "err" || kv.StringValue() != "null" {
+		t.Errorf("unexpected %q: %s", kv.Key, kv.StringValue())
+	}
+	kv = Err(errors.New("some\nerror"))
+	if kv.StringValue() != `"some\nerror"` {
+		t.Errorf("unexpected %s", kv.StringValue())
+	}
+	var ip net.IP
+	kv = Stringer("ip", ip)
+	if kv.StringValue() != "null" {
+		t.Errorf("unexpected %s", kv.StringValue())
+	}
+	kv = Stringer("ip", net.IPv4(10, 0, 0, 1))
+	if kv.StringValue() != `"10.0.0.1"` {
+		t.Errorf("unexpected %s", kv.StringValue())
+	}
+	var ptr *net.TCPAddr
+	kv = Stringer("addr", ptr)
+	if kv.StringValue() != "null" {
+		t.Errorf("unexpected %s", kv.StringValue())
+	}
+	kv = Stringer("nil", nil)
+	if kv.StringValue() != "null" {
+		t.Errorf("unexpected %s", kv.StringValue())
+	}
+	kv = Hex("hex", []byte{0x0a, 0xff})
+	if kv.StringValue() != `"0aff"` {
+		t.Errorf("unexpected %s", kv.StringValue())
+	}
+	kv = Hex("hex", nil)
+	if kv.StringValue() != "null" {
+		t.Errorf("unexpected %s", kv.StringValue())
+	}
+	kv = Base64("b64", []byte("hello"))
+	if kv.StringValue() != `"aGVsbG8="` {
+		t.Errorf("unexpected %s", kv.StringValue())
+	}
+	kv = Base64("b64", nil)
+	if kv.StringValue() != "null" {
+		t.Errorf("unexpected %s", kv.StringValue())
+	}
+	kv = Uint64("u", 18446744073709551615)
+	if kv.StringValue() != "18446744073709551615" {
+		t.Errorf("unexpected %s", kv.StringValue())
+	}
+}
+
+func TestTypedAttributesOutput(t *testing.T) {
+	SetLogLevelQuiet(Info)
+	Config.LogFileAndLine = false
+	Config.JSON = true
+	Config.NoTimestamp = true
+	Config.GoroutineID = false
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	attrs := []KeyVal{Duration("took", 2*time.Second), Err(nil), Hex("id", []byte{1, 2})}
+	S(Info, "json", attrs...)
+	Config.JSON = false
+	SetFlags(0)
+	Config.LogPrefix = " "
+	S(Info, "text", attrs...)
+	actual := buf.String()
+	expected := `{"level":"info","msg":"json","took":2,"err":null,"id":"0102"}` + "\n" +
+		`[I] text, took=2s, err=null, id="0102"` + "\n"
+	if actual != expected {
+		t.Errorf("unexpected:\n%s\nvs:\n%s\n", actual, expected)
+	}
+	Config.GoroutineID = true
+}
+
+func TestGroup(t *testing.T) {
+	SetLogLevelQuiet(Info)
+	Config.LogFileAndLine = false
+	Config.JSON = true
+	Config.NoTimestamp = true
+	Config.GoroutineID = false
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	calls := 0
+	lazy := Lazy("lazy", func() any {
+		calls++
+		return calls
+	})
+	attrs := []KeyVal{
+		Str("before", "x"),
+		Group("http", Str("method", "GET"), Int("status", 200),
+			Group("resp", Duration("took", 10*time.Millisecond), lazy)),
+		Group("empty"),
+	}
+	S(Info, "json", attrs...)
+	Config.JSON = false
+	SetFlags(0)
+	Config.LogPrefix = " "
+	S(Info, "text", attrs...)
+	actual := buf.String()
+	expected := `{"level":"info","msg":"json","before":"x","http":{"method":"GET","status":200,` +
+		`"resp":{"took":0.01,"lazy":1}},"empty":{}}` + "\n" +
+		`[I] text, before="x", http.method="GET", http.status=200, http.resp.took=10ms, http.resp.lazy=1` + "\n"
+	if actual != expected {
+		t.Errorf("unexpected:\n%s\nvs:\n%s\n", actual, expected)
+	}
+	if calls != 1 {
+		t.Errorf("expected lazy to be evaluated once, got %d", calls)
+	}
+	Config.GoroutineID = true
+}
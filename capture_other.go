@@ -0,0 +1,33 @@
+// Copyright 2026 Fortio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+
+package log // import "fortio.org/log"
+
+import (
+	"errors"
+	"runtime"
+)
+
+// Levels at which the lines captured by CaptureStdFDs are logged (unused on this platform).
+var (
+	CapturedStdoutLevel = Info
+	CapturedStderrLevel = Warning
+)
+
+// CaptureStdFDs is not supported on this platform (it needs unix file descriptors).
+func CaptureStdFDs() (func(), error) {
+	return nil, errors.New("log: CaptureStdFDs is not supported on " + runtime.GOOS)
+}
@@ -0,0 +1,68 @@
+// Copyright 2026 Fortio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package log // import "fortio.org/log"
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+// Global so it's never garbage collected (which would close fd 2).
+var stderrCopy = os.NewFile(2, "stderr-copy")
+
+func TestCaptureStdFDs(t *testing.T) {
+	SetLogLevel(Info)
+	Config.LogFileAndLine = false
+	Config.JSON = true
+	Config.NoTimestamp = true
+	Config.GoroutineID = false
+	var b bytes.Buffer
+	w := bufio.NewWriter(&b)
+	SetOutput(w)
+	stop, err := CaptureStdFDs()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	fmt.Fprintln(os.Stderr, "to stderr")
+	fmt.Fprint(os.Stdout, "to stdout\nno newline at the end")
+	Infof("regular")
+	stop()
+	stop() // no-op
+	w.Flush()
+	actual := b.String()
+	for _, expected := range []string{
+		`{"level":"warn","msg":"to stderr","src":"stderr"}` + "\n",
+		`{"level":"info","msg":"to stdout","src":"stdout"}` + "\n",
+		`{"level":"info","msg":"no newline at the end","src":"stdout"}` + "\n",
+		`{"level":"info","msg":"regular"}` + "\n",
+	} {
+		if !strings.Contains(actual, expected) {
+			t.Errorf("missing %s in:\n%s", expected, actual)
+		}
+	}
+	// Another *os.File for fd 2 would loop.
+	SetOutput(stderrCopy)
+	if _, err = CaptureStdFDs(); err == nil {
+		t.Errorf("expected error for an output writing to fd 2")
+	}
+	SetOutput(os.Stderr)
+	Config.GoroutineID = true
+}
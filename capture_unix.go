@@ -0,0 +1,125 @@
+// Copyright 2026 Fortio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+// Capture of the writes to the stdout and stderr file descriptors.
+
+package log // import "fortio.org/log"
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"syscall"
+)
+
+// Levels at which the lines captured by CaptureStdFDs are logged.
+var (
+	CapturedStdoutLevel = Info
+	CapturedStderrLevel = Warning
+)
+
+// capturedFD is one of the redirected file descriptors.
+type capturedFD struct {
+	fd   int
+	orig *os.File // duplicate of the original fd, for our own output and to restore it.
+}
+
+// capture duplicates fd and redirects it to a pipe whose lines are logged with src.
+func (c *capturedFD) capture(src string, lvl Level, wg *sync.WaitGroup) error {
+	dup, err := syscall.Dup(c.fd)
+	if err != nil {
+		return err
+	}
+	syscall.CloseOnExec(dup)
+	c.orig = os.NewFile(uintptr(dup), src+"-orig")
+	r, w, err := os.Pipe()
+	if err != nil {
+		c.orig.Close()
+		return err
+	}
+	err = dup2(int(w.Fd()), c.fd)
+	w.Close() // the fd now keeps the pipe's write end open.
+	if err != nil {
+		r.Close()
+		c.orig.Close()
+		return err
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		logLines(r, lvl, Str("src", src))
+		r.Close()
+	}()
+	return nil
+}
+
+// restore points the fd back to the original, which closes the pipe (ending the reading goroutine).
+func (c *capturedFD) restore() error {
+	err := dup2(int(c.orig.Fd()), c.fd)
+	return err
+}
+
+// CaptureStdFDs redirects the stdout and stderr file descriptors (1 and 2) through pipes so everything
+// written to them (by cgo libraries, child processes inheriting them, stray fmt.Println, etc.) is logged
+// line by line as entries with a "src" attribute of "stdout" (at CapturedStdoutLevel) or "stderr"
+// (at CapturedStderrLevel). Our own output, if it was os.Stdout or os.Stderr, is switched to a duplicate of
+// the original file descriptor. The returned function stops the capture, restoring the file descriptors
+// and the output, after logging the remaining captured lines.
+// Other outputs writing to fd 1 or 2 would feed our own entries back into the capture, endlessly:
+// another *os.File for them is detected and refused, but writers wrapping them (e.g. a bufio.Writer around
+// os.Stderr) can't be, so switch such outputs to os.Stdout or os.Stderr (or another destination) first.
+// Note that the fatal error messages of the go runtime (e.g. for unrecovered panics) go to the capture
+// pipe too and can thus be lost when the process crashes.
+func CaptureStdFDs() (func(), error) {
+	var wg sync.WaitGroup
+	stdout := &capturedFD{fd: 1}
+	stderr := &capturedFD{fd: 2}
+	prevOutput := jWriter.w
+	if f, ok := prevOutput.(*os.File); ok && f != os.Stdout && f != os.Stderr && (f.Fd() == 1 || f.Fd() == 2) {
+		return nil, errors.New("log: output is a file for fd 1 or 2 which would loop, use os.Stdout or os.Stderr instead")
+	}
+	if err := stdout.capture("stdout", CapturedStdoutLevel, &wg); err != nil {
+		return nil, err
+	}
+	if err := stderr.capture("stderr", CapturedStderrLevel, &wg); err != nil {
+		_ = stdout.restore()
+		wg.Wait()
+		stdout.orig.Close()
+		return nil, err
+	}
+	switch prevOutput {
+	case os.Stdout:
+		SetOutput(stdout.orig)
+	case os.Stderr:
+		SetOutput(stderr.orig)
+	}
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			errOut := stdout.restore()
+			errErr := stderr.restore()
+			wg.Wait()
+			if prevOutput == os.Stdout || prevOutput == os.Stderr {
+				SetOutput(prevOutput)
+			}
+			stdout.orig.Close()
+			stderr.orig.Close()
+			if errOut != nil || errErr != nil {
+				Errf("Error restoring the stdout/stderr file descriptors: %v %v", errOut, errErr)
+			}
+		})
+	}, nil
+}
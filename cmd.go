@@ -0,0 +1,92 @@
+// Copyright 2026 Fortio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !no_exec
+
+// Logging of the output and exit of subprocesses.
+
+package log // import "fortio.org/log"
+
+import (
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// StartCmd starts cmd with its stdout and stderr logged line by line at stdoutLevel and stderrLevel
+// respectively, with cmd (base name of the command), pid and stream ("stdout" or "stderr") attributes.
+// cmd.Stdout and cmd.Stderr must not be set. The returned wait function must be called (instead of cmd.Wait())
+// to wait for the output to be logged and the command to exit; it then logs the exit code and the
+// duration in microseconds, at Info level if the command succeeded and Error otherwise, and returns
+// cmd.Wait()'s error.
+func StartCmd(cmd *exec.Cmd, stdoutLevel, stderrLevel Level) (wait func() error, err error) {
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	name := filepath.Base(cmd.Path)
+	start := time.Now()
+	if err = cmd.Start(); err != nil {
+		s(Error, false, Config.JSON, "Unable to start command", Str("cmd", name), Err(err))
+		return nil, err
+	}
+	pid := cmd.Process.Pid
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		logLines(stdout, stdoutLevel, Str("cmd", name), Int("pid", pid), Str("stream", "stdout"))
+	}()
+	go func() {
+		defer wg.Done()
+		logLines(stderr, stderrLevel, Str("cmd", name), Int("pid", pid), Str("stream", "stderr"))
+	}()
+	return func() error {
+		wg.Wait() // all the output must be read before calling cmd.Wait() (which closes the pipes).
+		err := cmd.Wait()
+		exitCode := -1
+		if cmd.ProcessState != nil {
+			exitCode = cmd.ProcessState.ExitCode()
+		}
+		attrs := []KeyVal{
+			Str("cmd", name), Int("pid", pid), Int("exit_code", exitCode),
+			Int64("microsec", time.Since(start).Microseconds()),
+		}
+		var exitErr *exec.ExitError
+		switch {
+		case err == nil:
+			s(Info, false, Config.JSON, "Command exited", attrs...)
+		case errors.As(err, &exitErr):
+			s(Error, false, Config.JSON, "Command failed", attrs...)
+		default:
+			s(Error, false, Config.JSON, "Command failed", append(attrs, Err(err))...)
+		}
+		return err
+	}, nil
+}
+
+// RunCmd runs cmd with its output and exit logged, see StartCmd.
+func RunCmd(cmd *exec.Cmd, stdoutLevel, stderrLevel Level) error {
+	wait, err := StartCmd(cmd, stdoutLevel, stderrLevel)
+	if err != nil {
+		return err
+	}
+	return wait()
+}
@@ -0,0 +1,92 @@
+// Copyright 2026 Fortio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !no_exec
+
+package log // import "fortio.org/log"
+
+import (
+	"bufio"
+	"bytes"
+	"os/exec"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRunCmd(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skipf("no shell: %v", err)
+	}
+	SetLogLevel(Info)
+	Config.LogFileAndLine = false
+	Config.JSON = true
+	Config.NoTimestamp = true
+	Config.GoroutineID = false
+	var b bytes.Buffer
+	w := bufio.NewWriter(&b)
+	SetOutput(w)
+	cmd := exec.Command(sh, "-c", "echo out1; echo err1 >&2; printf 'out2'; exit 3")
+	err = RunCmd(cmd, Info, Warning)
+	if err == nil {
+		t.Errorf("expected exit error")
+	}
+	w.Flush()
+	actual := regexp.MustCompile(`"pid":\d+`).ReplaceAllString(b.String(), `"pid":1`)
+	actual = regexp.MustCompile(`"microsec":\d+`).ReplaceAllString(actual, `"microsec":0`)
+	for _, expected := range []string{
+		`{"level":"info","msg":"out1","cmd":"sh","pid":1,"stream":"stdout"}` + "\n",
+		`{"level":"info","msg":"out2","cmd":"sh","pid":1,"stream":"stdout"}` + "\n",
+		`{"level":"warn","msg":"err1","cmd":"sh","pid":1,"stream":"stderr"}` + "\n",
+	} {
+		if !strings.Contains(actual, expected) {
+			t.Errorf("missing %s in:\n%s", expected, actual)
+		}
+	}
+	expected := `{"level":"err","msg":"Command failed","cmd":"sh","pid":1,"exit_code":3,"microsec":0}` + "\n"
+	if !strings.HasSuffix(actual, expected) {
+		t.Errorf("unexpected:\n%s\nvs:\n%s\n", actual, expected)
+	}
+	// Success, level filtering and double set error. No file and line (which would be cmd.go's).
+	Config.LogFileAndLine = true
+	b.Reset()
+	cmd = exec.Command(sh, "-c", "echo debug output")
+	err = RunCmd(cmd, Debug, Error)
+	if err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	w.Flush()
+	actual = regexp.MustCompile(`"pid":\d+,"exit_code":0,"microsec":\d+`).ReplaceAllString(b.String(), `"pid":1,"exit_code":0,"microsec":0`)
+	expected = `{"level":"info","msg":"Command exited","cmd":"sh","pid":1,"exit_code":0,"microsec":0}` + "\n"
+	if actual != expected {
+		t.Errorf("unexpected:\n%s\nvs:\n%s\n", actual, expected)
+	}
+	Config.LogFileAndLine = false
+	cmd = exec.Command(sh, "-c", "true")
+	cmd.Stdout = &b
+	if _, err = StartCmd(cmd, Info, Info); err == nil {
+		t.Errorf("expected error for already set Stdout")
+	}
+	b.Reset()
+	err = RunCmd(exec.Command("/this/does/not/exist"), Info, Info)
+	if err == nil {
+		t.Errorf("expected start error")
+	}
+	w.Flush()
+	if !strings.Contains(b.String(), `"msg":"Unable to start command","cmd":"exist","err":`) {
+		t.Errorf("unexpected start error log: %s", b.String())
+	}
+	Config.GoroutineID = true
+}
@@ -163,5 +163,5 @@ func ColorLevelToStr(lvl Level) string {
 	if lvl == NoLevel {
 		return Colors.DarkGray
 	}
-	return Colors.DarkGray + "[" + LevelToColor[lvl] + LevelToText[lvl] + Colors.DarkGray + "]"
+	return Colors.DarkGray + "[" + lvl.color() + lvl.text() + Colors.DarkGray + "]"
 }
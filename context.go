@@ -0,0 +1,185 @@
+// Copyright 2026 Fortio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Context scoped attributes (e.g. request or trace ids).
+
+package log // import "fortio.org/log"
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"sync"
+)
+
+type ctxAttrsKey struct{}
+
+// WithAttrs returns a copy of ctx carrying attrs, in addition to the ones ctx already carries,
+// which SCtx adds to every entry logged with the returned context.
+func WithAttrs(ctx context.Context, attrs ...KeyVal) context.Context {
+	if len(attrs) == 0 {
+		return ctx
+	}
+	existing := ContextAttrs(ctx)
+	all := make([]KeyVal, 0, len(existing)+len(attrs))
+	all = append(all, existing...)
+	all = append(all, attrs...)
+	return context.WithValue(ctx, ctxAttrsKey{}, all)
+}
+
+// ContextAttrs returns the attributes added to ctx by WithAttrs (nil if none). The returned slice must
+// not be modified.
+func ContextAttrs(ctx context.Context) []KeyVal {
+	if ctx == nil {
+		return nil
+	}
+	attrs, _ := ctx.Value(ctxAttrsKey{}).([]KeyVal)
+	return attrs
+}
+
+// SCtx is S() with the attributes carried by ctx (see WithAttrs) logged first.
+// For requests served by LogAndCall with the RequestLogBufferSize or LogLevelHeader options set,
+// entries below the current log level may also be buffered or logged (see [HTTPLogOptions]).
+func SCtx(ctx context.Context, lvl Level, msg string, attrs ...KeyVal) {
+	var b *logBuffer
+	if !Log(lvl) {
+		st := ctxLogState(ctx)
+		switch {
+		case st == nil:
+			return
+		case st.hasLevel && lvl >= st.level:
+			// escalated request: logged directly.
+		case st.buf != nil:
+			b = st.buf
+		default:
+			return
+		}
+	}
+	if countingCallSites() {
+		countCaller(1)
+	}
+	ctxAttrs := ContextAttrs(ctx)
+	if len(ctxAttrs) > 0 {
+		// copy (also of the ctx ones) as the attributes' lazily computed values are cached in place.
+		all := make([]KeyVal, 0, len(ctxAttrs)+len(attrs))
+		all = append(all, ctxAttrs...)
+		attrs = append(all, attrs...)
+	}
+	sCtx(b, lvl, Config.LogFileAndLine, Config.JSON, msg, attrs...)
+}
+
+// sCtx is there to call emit() at the same depth as s().
+func sCtx(b *logBuffer, lvl Level, logFileAndLine bool, json bool, msg string, attrs ...KeyVal) {
+	emit(b, lvl, logFileAndLine, json, msg, attrs...)
+}
+
+type ctxLogStateKey struct{}
+
+// requestLogState is the per request (context) logging state: level override and buffer.
+type requestLogState struct {
+	hasLevel bool
+	level    Level
+	buf      *logBuffer
+}
+
+func withLogState(ctx context.Context, st *requestLogState) context.Context {
+	return context.WithValue(ctx, ctxLogStateKey{}, st)
+}
+
+func ctxLogState(ctx context.Context) *requestLogState {
+	if ctx == nil {
+		return nil
+	}
+	st, _ := ctx.Value(ctxLogStateKey{}).(*requestLogState)
+	return st
+}
+
+// logBuffer holds formatted entries until they are flushed or discarded, see finish().
+type logBuffer struct {
+	mu      sync.Mutex
+	buf     bytes.Buffer
+	max     int
+	dropped int
+	done    bool
+	flushed bool
+	levels  []Level // of the buffered entries, counted (see LevelCount) only if they are flushed.
+}
+
+func newLogBuffer(maxSize int) *logBuffer {
+	return &logBuffer{max: maxSize}
+}
+
+// write writes the line (of an entry at lvl) to the output if b is nil, buffers it otherwise (up to max bytes).
+func (b *logBuffer) write(lvl Level, line string) {
+	if b == nil {
+		countLevel(lvl)
+		jsonWrite(line)
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch {
+	case b.flushed:
+		countLevel(lvl)
+		jsonWrite(line)
+	case b.done:
+		// discarded.
+	case b.buf.Len()+len(line) > b.max:
+		b.dropped++
+		countDropped(1)
+	default:
+		b.buf.WriteString(line)
+		b.levels = append(b.levels, lvl)
+	}
+}
+
+// print is log.Print() (text mode) to the output or into b.
+func (b *logBuffer) print(lvl Level, v ...any) {
+	if b == nil {
+		countLevel(lvl)
+		stdPrint(v...)
+		return
+	}
+	var line bytes.Buffer
+	log.New(&line, log.Prefix(), log.Flags()).Print(v...) // same format (flags) as the standard logger.
+	b.write(lvl, line.String())
+}
+
+// finish writes the buffered entries to the output if flush is true or discards them otherwise.
+// Only the first call has effect; entries written after are written directly if flushed or dropped.
+func (b *logBuffer) finish(flush bool) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	if b.done {
+		b.mu.Unlock()
+		return
+	}
+	b.done = true
+	b.flushed = flush
+	if flush && b.buf.Len() > 0 {
+		for _, lvl := range b.levels {
+			countLevel(lvl)
+		}
+		jsonWriteBytes(b.buf.Bytes())
+	}
+	b.buf = bytes.Buffer{}
+	b.levels = nil
+	dropped := b.dropped
+	b.mu.Unlock()
+	if flush && dropped > 0 {
+		s(Warning, false, Config.JSON, "request log buffer full", Int("dropped", dropped))
+	}
+}
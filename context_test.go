@@ -0,0 +1,86 @@
+// Copyright 2026 Fortio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log // import "fortio.org/log"
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestWithAttrs(t *testing.T) {
+	SetLogLevelQuiet(Info)
+	Config.LogFileAndLine = false
+	Config.JSON = true
+	Config.NoTimestamp = true
+	Config.GoroutineID = false
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	ctx := context.Background()
+	if WithAttrs(ctx) != ctx || ContextAttrs(ctx) != nil {
+		t.Errorf("expected no attributes")
+	}
+	ctx1 := WithAttrs(ctx, Str("a", "1"))
+	ctx2 := WithAttrs(ctx1, Int("b", 2))
+	SCtx(ctx1, Info, "one")
+	SCtx(ctx2, Info, "two", Bool("c", true))
+	SCtx(ctx2, Debug, "not logged")
+	SCtx(nil, Info, "none") //nolint:staticcheck // testing nil context.
+	actual := buf.String()
+	expected := `{"level":"info","msg":"one","a":"1"}
+{"level":"info","msg":"two","a":"1","b":2,"c":true}
+{"level":"info","msg":"none"}
+`
+	if actual != expected {
+		t.Errorf("unexpected:\n%s\nvs:\n%s\n", actual, expected)
+	}
+	Config.GoroutineID = true
+}
+
+func TestSCtxBufferTextMode(t *testing.T) {
+	SetLogLevelQuiet(Info)
+	Config.LogFileAndLine = false
+	Config.JSON = false
+	Config.GoroutineID = false
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	SetFlags(0)
+	Config.LogPrefix = " "
+	b := newLogBuffer(1000)
+	ctx := withLogState(WithAttrs(context.Background(), Str("id", "x")), &requestLogState{buf: b})
+	SCtx(ctx, Debug, "buffered")
+	SCtx(ctx, Info, "direct")
+	b.finish(true)
+	SCtx(ctx, Debug, "after flush")
+	b.finish(false) // no-op
+	actual := buf.String()
+	expected := `[I] direct, id="x"
+[D] buffered, id="x"
+[D] after flush, id="x"
+`
+	if actual != expected {
+		t.Errorf("unexpected:\n%s\nvs:\n%s\n", actual, expected)
+	}
+	buf.Reset()
+	b = newLogBuffer(1000)
+	ctx = withLogState(context.Background(), &requestLogState{buf: b})
+	SCtx(ctx, Debug, "discarded")
+	b.finish(false)
+	SCtx(ctx, Debug, "discarded too")
+	if buf.Len() != 0 {
+		t.Errorf("unexpected output %q", buf.String())
+	}
+	Config.GoroutineID = true
+}
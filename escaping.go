@@ -0,0 +1,155 @@
+// Copyright 2026 Fortio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// String escaping/quoting shared by all the output modes.
+
+package log // import "fortio.org/log"
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const hexDigits = "0123456789abcdef"
+
+// jsonQuote returns s as a valid JSON string literal (RFC 8259): quotes, backslashes
+// and control characters are escaped and invalid UTF-8 is replaced by U+FFFD.
+// Unlike %q/strconv.Quote it never produces \x or \U escapes which JSON parsers reject.
+// Like encoding/json, U+2028 and U+2029 are also escaped but unlike it we don't HTML escape <, > and &.
+func jsonQuote(s string) string {
+	i := 0
+	for ; i < len(s); i++ {
+		c := s[i]
+		if c < 0x20 || c == '"' || c == '\\' || c >= utf8.RuneSelf {
+			break
+		}
+	}
+	if i == len(s) { // fast path, nothing to escape.
+		return "\"" + s + "\""
+	}
+	var buf strings.Builder
+	buf.Grow(len(s) + 8)
+	buf.WriteByte('"')
+	buf.WriteString(s[:i])
+	for i < len(s) {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			switch c {
+			case '"', '\\':
+				buf.WriteByte('\\')
+				buf.WriteByte(c)
+			case '\n':
+				buf.WriteString(`\n`)
+			case '\r':
+				buf.WriteString(`\r`)
+			case '\t':
+				buf.WriteString(`\t`)
+			default:
+				if c < 0x20 {
+					buf.WriteString(`\u00`)
+					buf.WriteByte(hexDigits[c>>4])
+					buf.WriteByte(hexDigits[c&0xF])
+				} else {
+					buf.WriteByte(c)
+				}
+			}
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			buf.WriteString(`\ufffd`)
+		case r == '\u2028' || r == '\u2029':
+			buf.WriteString(`\u202`)
+			buf.WriteByte(hexDigits[r&0xF])
+		default:
+			buf.WriteString(s[i : i+size])
+		}
+		i += size
+	}
+	buf.WriteByte('"')
+	return buf.String()
+}
+
+// jsonString quotes s for JSON output: when Config.StrictJSON is set (the default) it
+// is guaranteed to be valid JSON, otherwise it's the (cheaper) go %q syntax.
+func jsonString(s string) string {
+	if Config.StrictJSON {
+		return jsonQuote(s)
+	}
+	return strconv.Quote(s)
+}
+
+// jsonFloat returns the representation of a float. When Config.StrictJSON is set
+// non finite values (NaN, +Inf, -Inf) are quoted to remain valid JSON.
+func jsonFloat[F float32 | float64](f F) string {
+	str := fmt.Sprint(f)
+	if Config.StrictJSON && (math.IsNaN(float64(f)) || math.IsInf(float64(f), 0)) {
+		return "\"" + str + "\""
+	}
+	return str
+}
+
+// needsTextEscape returns true if s contains characters that textEscape would change.
+func needsTextEscape(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c < 0x20 && c != '\t') || c == 0x7f || (c == 0xC2 && i+1 < len(s) && s[i+1] >= 0x80 && s[i+1] <= 0x9F) {
+			return true
+		}
+	}
+	return false
+}
+
+// textEscape neutralizes control characters (newlines, carriage returns, ANSI escape sequences, etc.)
+// in s for text and color output, so user controlled input can't forge log lines or repaint the terminal.
+// Tabs are kept. A single trailing newline is removed (the entry is newline terminated already).
+// Newlines are escaped as \n unless Config.IndentMultiLine is set, in which case they are kept but
+// followed by a tab so continuation lines are visibly part of the same entry (also for the unleveled
+// Printf output). Noop if Config.EscapeText is false.
+func textEscape(s string) string {
+	if !Config.EscapeText || !needsTextEscape(s) {
+		return s
+	}
+	s = strings.TrimSuffix(s, "\n")
+	var buf strings.Builder
+	buf.Grow(len(s) + 8)
+	for _, r := range s {
+		switch {
+		case r == '\n' && Config.IndentMultiLine:
+			buf.WriteString("\n\t")
+		case r == '\n':
+			buf.WriteString(`\n`)
+		case r == '\r':
+			buf.WriteString(`\r`)
+		case r == '\t':
+			buf.WriteByte('\t')
+		case r < 0x20 || r == 0x7f:
+			buf.WriteString(`\x`)
+			buf.WriteByte(hexDigits[r>>4])
+			buf.WriteByte(hexDigits[r&0xF])
+		case r >= 0x80 && r <= 0x9F:
+			buf.WriteString(`\u00`)
+			buf.WriteByte(hexDigits[r>>4])
+			buf.WriteByte(hexDigits[r&0xF])
+		default:
+			buf.WriteRune(r)
+		}
+	}
+	return buf.String()
+}
@@ -0,0 +1,168 @@
+// Copyright 2026 Fortio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Level and fatal hooks, flushing and closing of the sinks before exiting.
+
+package log // import "fortio.org/log"
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"fortio.org/log/goroutine"
+)
+
+// levelHook is the Sink calling the OnLevel functions.
+type levelHook struct {
+	level Level
+	fn    func(e *Entry)
+}
+
+func (h *levelHook) LogEntry(e *Entry) {
+	if e.Level >= h.level && e.Level != NoLevel {
+		h.fn(e)
+	}
+}
+
+// OnLevel registers fn to be called for every entry logged at lvl or above (e.g. OnLevel(Error, ...) to
+// count or report errors). Like for sinks, fn is called synchronously and must not log itself.
+// The returned function unregisters it.
+func OnLevel(lvl Level, fn func(e *Entry)) (remove func()) {
+	h := &levelHook{level: lvl, fn: fn}
+	AddSink(h)
+	return func() {
+		RemoveSink(h)
+	}
+}
+
+var (
+	fatalHooksMutex sync.Mutex
+	fatalHooks      []func()
+	fatalRun        *fatalHooksRun // in progress run of the fatal hooks, if any.
+)
+
+// fatalHooksRun tracks a run of the fatal hooks so concurrent fatal calls wait for it to complete.
+type fatalHooksRun struct {
+	done chan struct{} // closed when the run completed (or timed out).
+	gid  int64         // atomic, goroutine running the hooks.
+}
+
+// OnFatal registers fn to be called, in registration order, when Fatalf (or FErrf and their ...Code
+// variants) is called, after the fatal message is logged and before the sinks are flushed and the
+// program exits (or panics). Use it to flush metrics, tracing exporters, etc.
+func OnFatal(fn func()) {
+	fatalHooksMutex.Lock()
+	fatalHooks = append(fatalHooks, fn)
+	fatalHooksMutex.Unlock()
+}
+
+// ErrFlushTimeout is returned by CloseSinks when the timeout expires.
+var ErrFlushTimeout = errors.New("log: timeout flushing the sinks")
+
+// flusher is implemented by sinks (and outputs, e.g. bufio.Writer) needing to be flushed.
+type flusher interface {
+	Flush() error
+}
+
+// CloseSinks flushes the sinks implementing Flush() error, then closes the ones implementing io.Closer
+// and removes those, and finally flushes the output if it implements Flush() error (e.g. a bufio.Writer).
+// It returns ErrFlushTimeout if that didn't complete within the timeout (0 for no timeout),
+// otherwise the first error encountered.
+func CloseSinks(timeout time.Duration) error {
+	return withTimeout(timeout, closeSinks)
+}
+
+func closeSinks() error {
+	var firstErr error
+	keepErr := func(err error) {
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	for _, rs := range getSinks() {
+		if f, ok := rs.sink.(flusher); ok {
+			keepErr(f.Flush())
+		}
+		if c, ok := rs.sink.(io.Closer); ok {
+			RemoveSink(rs.sink)
+			keepErr(c.Close())
+		}
+	}
+	jWriter.mutex.Lock()
+	if f, ok := jWriter.w.(flusher); ok {
+		keepErr(f.Flush())
+	}
+	jWriter.mutex.Unlock()
+	return firstErr
+}
+
+// withTimeout runs fn in a goroutine and waits for it up to the timeout (no limit if 0 or less).
+func withTimeout(timeout time.Duration, fn func() error) error {
+	if timeout <= 0 {
+		return fn()
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- fn()
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		return ErrFlushTimeout
+	}
+}
+
+// runFatalHooks calls the OnFatal functions and then CloseSinks, all within Config.FatalFlushTimeout.
+// Fatal calls from other goroutines meanwhile wait for that to complete (so they don't exit before
+// the sinks are flushed) while fatal calls from the hooks themselves don't run them again.
+func runFatalHooks() {
+	fatalHooksMutex.Lock()
+	if run := fatalRun; run != nil {
+		fatalHooksMutex.Unlock()
+		if atomic.LoadInt64(&run.gid) != goroutine.ID() {
+			<-run.done
+		}
+		return
+	}
+	run := &fatalHooksRun{done: make(chan struct{})}
+	fatalRun = run
+	hooks := append([]func(){}, fatalHooks...)
+	fatalHooksMutex.Unlock()
+	_ = withTimeout(Config.FatalFlushTimeout, func() error {
+		atomic.StoreInt64(&run.gid, goroutine.ID())
+		for _, fn := range hooks {
+			fn()
+		}
+		return closeSinks()
+	})
+	close(run.done)
+	fatalHooksMutex.Lock()
+	fatalRun = nil // in case the exit doesn't happen (FErrf, tests).
+	fatalHooksMutex.Unlock()
+}
+
+// fatalExit runs the fatal hooks then panics or exits with code, per Config.
+func fatalExit(code int) {
+	runFatalHooks()
+	if Config.FatalPanics {
+		panic("aborting...")
+	}
+	Config.FatalExit(code)
+}
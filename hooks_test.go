@@ -0,0 +1,159 @@
+// Copyright 2026 Fortio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log // import "fortio.org/log"
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// closingSink records the flush and close calls.
+type closingSink struct {
+	entries, flushes, closes int
+}
+
+func (c *closingSink) LogEntry(_ *Entry) { c.entries++ }
+
+func (c *closingSink) Flush() error {
+	c.flushes++
+	return nil
+}
+
+func (c *closingSink) Close() error {
+	c.closes++
+	return errors.New("close error")
+}
+
+func TestHooks(t *testing.T) {
+	SetLogLevel(Info)
+	Config.LogFileAndLine = false
+	Config.JSON = true
+	Config.NoTimestamp = true
+	Config.GoroutineID = false
+	var b bytes.Buffer
+	w := bufio.NewWriter(&b)
+	SetOutput(w)
+	var levels []Level
+	remove := OnLevel(Warning, func(e *Entry) {
+		levels = append(levels, e.Level)
+	})
+	Infof("not hooked")
+	Warnf("hooked")
+	S(Critical, "hooked too")
+	Printf("no level, not hooked")
+	remove()
+	Errf("not hooked after removal")
+	if len(levels) != 2 || levels[0] != Warning || levels[1] != Critical {
+		t.Errorf("unexpected hooked levels %v", levels)
+	}
+	// Fatal hooks, sink closing and exit codes.
+	sink := &closingSink{}
+	AddSink(sink)
+	var calls []string
+	OnFatal(func() {
+		calls = append(calls, "first")
+		Fatalf("fatal from a hook doesn't recurse")
+	})
+	OnFatal(func() { calls = append(calls, "second") })
+	exitCode := 0
+	prevPanics := Config.FatalPanics
+	Config.FatalPanics = false
+	Config.FatalExit = func(code int) {
+		exitCode = code
+	}
+	FatalfCode(42, "fatal with code")
+	if exitCode != 42 {
+		t.Errorf("unexpected exit code %d", exitCode)
+	}
+	if len(calls) != 2 || calls[0] != "first" || calls[1] != "second" {
+		t.Errorf("unexpected fatal hook calls %v", calls)
+	}
+	if sink.entries != 2 || sink.flushes != 1 || sink.closes != 1 || len(getSinks()) != 0 {
+		t.Errorf("unexpected sink state %+v, %d sinks", sink, len(getSinks()))
+	}
+	if b.Len() == 0 {
+		t.Errorf("output should have been flushed by Fatalf")
+	}
+	if code := FErrfCode(3, "fatal error"); code != 3 || len(calls) != 4 {
+		t.Errorf("unexpected FErrfCode %d, %v", code, calls)
+	}
+	fatalHooks = nil
+	Config.FatalExit = os.Exit
+	Config.FatalPanics = prevPanics
+	// Errors and timeout.
+	AddSink(sink)
+	if err := CloseSinks(0); err == nil || err.Error() != "close error" {
+		t.Errorf("unexpected close error %v", err)
+	}
+	if err := CloseSinks(time.Second); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	release := make(chan struct{})
+	done := make(chan struct{})
+	err := withTimeout(10*time.Millisecond, func() error {
+		<-release
+		close(done)
+		return nil
+	})
+	if !errors.Is(err, ErrFlushTimeout) {
+		t.Errorf("expected timeout, got %v", err)
+	}
+	close(release)
+	<-done
+	Config.GoroutineID = true
+}
+
+func TestConcurrentFatalWaitsForHooks(t *testing.T) {
+	SetOutput(&bytes.Buffer{})
+	prevPanics := Config.FatalPanics
+	Config.FatalPanics = false
+	var mu sync.Mutex
+	var events []string
+	record := func(e string) {
+		mu.Lock()
+		events = append(events, e)
+		mu.Unlock()
+	}
+	Config.FatalExit = func(code int) {
+		record("exit " + strconv.Itoa(code))
+	}
+	other := make(chan struct{})
+	OnFatal(func() {
+		go func() {
+			FatalfCode(2, "concurrent fatal")
+			close(other)
+		}()
+		time.Sleep(50 * time.Millisecond) // lets the other Fatalf reach runFatalHooks.
+		record("hook done")
+	})
+	FatalfCode(1, "first fatal")
+	<-other
+	mu.Lock()
+	actual := strings.Join(events, ", ")
+	mu.Unlock()
+	if actual != "hook done, exit 1, exit 2" && actual != "hook done, exit 2, exit 1" {
+		t.Errorf("concurrent fatal call should wait for the hooks, got %s", actual)
+	}
+	fatalHooks = nil
+	Config.FatalExit = os.Exit
+	Config.FatalPanics = prevPanics
+}
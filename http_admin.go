@@ -0,0 +1,160 @@
+// Copyright 2026 Fortio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !no_http && !no_net
+
+// Admin http endpoint to view and change the logger configuration at runtime.
+
+package log // import "fortio.org/log"
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync/atomic"
+)
+
+// AdminChange is the body of the POST/PUT requests to the AdminHandler, only the set fields are changed.
+// e.g. {"level": "debug", "json": false}.
+type AdminChange struct {
+	Level          *string `json:"level,omitempty"`
+	JSON           *bool   `json:"json,omitempty"`
+	Color          *bool   `json:"color,omitempty"` // forces color mode on (ForceColor) or off (no ForceColor nor ConsoleColor).
+	LogFileAndLine *bool   `json:"log_file_and_line,omitempty"`
+	GoroutineID    *bool   `json:"goroutine_id,omitempty"`
+	NoTimestamp    *bool   `json:"no_timestamp,omitempty"`
+	// Named sinks (see AddNamedSink) to enable (true) or disable (false), e.g. {"sinks": {"ring": false}}.
+	Sinks map[string]bool `json:"sinks,omitempty"`
+}
+
+// adminState is what the AdminHandler returns: the Config fields and the named sinks' states.
+type adminState struct {
+	*LogConfig
+	Sinks []SinkState `json:"sinks,omitempty"`
+}
+
+// AdminHandler returns a http.Handler (to mount for instance on /debug/log) which returns the
+// current Config as JSON on GET and applies an [AdminChange] JSON body on POST or PUT, if authorize
+// returns true for the request (if authorize is nil the handler is read only). Changes are logged,
+// the level through the usual "Log level is now" entry. Unknown fields are rejected.
+// The named sinks (see [AddNamedSink]) are listed, with whether they are enabled, as "sinks" and
+// can be disabled and re-enabled. There are no per-module levels to change (the level is global).
+func AdminHandler(authorize func(r *http.Request) bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+		case http.MethodPost, http.MethodPut:
+			if authorize == nil || !authorize(r) {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+			var change AdminChange
+			dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096))
+			dec.DisallowUnknownFields()
+			if err := dec.Decode(&change); err != nil {
+				http.Error(w, "Invalid change: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := applyAdminChange(&change); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		default:
+			w.Header().Set("Allow", "GET, HEAD, POST, PUT")
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		jWriter.mutex.Lock()
+		data, err := json.MarshalIndent(adminState{Config, SinkStates()}, "", "  ")
+		jWriter.mutex.Unlock()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(append(data, '\n'))
+	})
+}
+
+// applyAdminChange validates and applies the change (as done by the AdminHandler), logging each
+// changed setting.
+func applyAdminChange(change *AdminChange) error {
+	for name := range change.Sinks { // validated first so invalid changes aren't partially applied.
+		if name == "" || findSink(name) == nil {
+			return fmt.Errorf("unknown sink %q", name)
+		}
+	}
+	if change.Level != nil {
+		lvl, err := ValidateLevel(*change.Level)
+		if err != nil || lvl > Critical {
+			return fmt.Errorf("invalid level %q: should be one of %v", *change.Level, LevelNames())
+		}
+		SetLogLevel(lvl)
+	}
+	setBool := func(name string, field *bool, value *bool) {
+		if value == nil {
+			return
+		}
+		prev := *field
+		if prev == *value {
+			return
+		}
+		// like for the level, logged before the change (so in the previous format).
+		if Log(Info) {
+			logUnconditionalf(false, Info, "Log %s is now %t (was %t)", name, *value, prev)
+		}
+		jWriter.mutex.Lock()
+		*field = *value
+		jWriter.mutex.Unlock()
+	}
+	setBool("json", &Config.JSON, change.JSON)
+	setBool("file and line", &Config.LogFileAndLine, change.LogFileAndLine)
+	setBool("goroutine id", &Config.GoroutineID, change.GoroutineID)
+	setBool("no timestamp", &Config.NoTimestamp, change.NoTimestamp)
+	if change.Color != nil {
+		prev := Color
+		jWriter.mutex.Lock()
+		Config.ForceColor = *change.Color
+		if !*change.Color {
+			Config.ConsoleColor = false
+		}
+		jWriter.mutex.Unlock()
+		SetColorMode()
+		if prev != Color && Log(Info) {
+			logUnconditionalf(false, Info, "Log color is now %t (was %t)", Color, prev)
+		}
+	}
+	names := make([]string, 0, len(change.Sinks))
+	for name := range change.Sinks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		rs := findSink(name)
+		if rs == nil { // removed concurrently.
+			continue
+		}
+		prev := atomic.LoadInt32(&rs.disabled) == 0
+		enabled := change.Sinks[name]
+		if prev == enabled {
+			continue
+		}
+		if Log(Info) {
+			logUnconditionalf(false, Info, "Log sink %q enabled is now %t (was %t)", name, enabled, prev)
+		}
+		_ = SetSinkEnabled(name, enabled) // explicitly ignored: the sink can only have been removed since.
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+// Copyright 2026 Fortio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !no_http && !no_net
+
+package log // import "fortio.org/log"
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAdminHandler(t *testing.T) {
+	SetLogLevelQuiet(Info)
+	Config.LogFileAndLine = false
+	Config.JSON = true
+	Config.NoTimestamp = true
+	Config.GoroutineID = false
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	handler := AdminHandler(func(r *http.Request) bool {
+		return r.Header.Get("Authorization") == "Bearer admin"
+	})
+	do := func(method, body string, auth bool) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/debug/log", strings.NewReader(body))
+		if auth {
+			r.Header.Set("Authorization", "Bearer admin")
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+	w := do(http.MethodGet, "", false)
+	var cfg LogConfig
+	if err := json.Unmarshal(w.Body.Bytes(), &cfg); err != nil || w.Code != http.StatusOK || cfg.Level != "Info" || !cfg.JSON {
+		t.Errorf("unexpected GET result %d %v: %s", w.Code, err, w.Body.String())
+	}
+	if w = do(http.MethodPost, `{"level":"debug"}`, false); w.Code != http.StatusForbidden || GetLogLevel() != Info {
+		t.Errorf("expected forbidden, got %d", w.Code)
+	}
+	if w = do(http.MethodPost, `{"level":"fatal"}`, true); w.Code != http.StatusBadRequest {
+		t.Errorf("expected bad request for invalid level, got %d", w.Code)
+	}
+	if w = do(http.MethodPut, `{"module_levels":{"foo":"debug"}}`, true); w.Code != http.StatusBadRequest {
+		t.Errorf("expected bad request for unknown field, got %d", w.Code)
+	}
+	if w = do(http.MethodDelete, "", true); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected method not allowed, got %d", w.Code)
+	}
+	w = do(http.MethodPost, `{"level":"verbose","goroutine_id":false,"log_file_and_line":false,"color":false}`, true)
+	if w.Code != http.StatusOK || GetLogLevel() != Verbose || !strings.Contains(w.Body.String(), `"Level": "Verbose"`) {
+		t.Errorf("unexpected change result %d: %s", w.Code, w.Body.String())
+	}
+	w = do(http.MethodPost, `{"json":false}`, true)
+	if w.Code != http.StatusOK || Config.JSON {
+		t.Errorf("unexpected change result %d: %s", w.Code, w.Body.String())
+	}
+	expected := `{"level":"info","msg":"Log level is now 1 Verbose (was 2 Info)"}
+{"level":"info","msg":"Log json is now false (was true)"}
+`
+	if actual := buf.String(); !strings.HasPrefix(actual, expected) {
+		t.Errorf("unexpected:\n%s\nvs:\n%s\n", actual, expected)
+	}
+	// Named sinks.
+	Config.JSON = true
+	rb := NewRingBuffer(1)
+	if err := AddNamedSink("ring", rb); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer RemoveSink(rb)
+	if w = do(http.MethodPost, `{"level":"info","sinks":{"ring":false,"foo":true}}`, true); w.Code != http.StatusBadRequest ||
+		GetLogLevel() != Verbose || len(SinkStates()) != 1 || !SinkStates()[0].Enabled {
+		t.Errorf("expected bad request for unknown sink and no change, got %d: %s", w.Code, w.Body.String())
+	}
+	buf.Reset()
+	w = do(http.MethodPost, `{"sinks":{"ring":false}}`, true)
+	if w.Code != http.StatusOK || SinkStates()[0].Enabled ||
+		!strings.Contains(w.Body.String(), `"sinks": [
+    {
+      "name": "ring",
+      "enabled": false
+    }
+  ]`) {
+		t.Errorf("unexpected sink change result %d: %s", w.Code, w.Body.String())
+	}
+	expected = `{"level":"info","msg":"Log sink \"ring\" enabled is now false (was true)"}` + "\n"
+	if actual := buf.String(); actual != expected {
+		t.Errorf("unexpected:\n%s\nvs:\n%s\n", actual, expected)
+	}
+	SetLogLevelQuiet(Info)
+	Config.JSON = true
+	Config.GoroutineID = true
+}
@@ -0,0 +1,167 @@
+// Copyright 2026 Fortio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !no_http && !no_net
+
+// Client side (outgoing requests) counterpart of LogAndCall.
+
+package log // import "fortio.org/log"
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Transport returns a http.RoundTripper logging each outgoing request and its response, using
+// the same attributes as LogAndCall (combined in one entry or not depending on Config.CombineRequestAndResponse)
+// plus the phase timings: dns_microsec, connect_microsec, tls_microsec and ttfb_microsec (time to the first
+// response byte, from the start of the request) when they happened, and whether the connection was reused.
+// microsec is the duration until the response headers were received (the body isn't read yet).
+// Errors are logged at Error level with an err attribute, so they are logged even when the level is
+// Warning or Error and the successful requests are not. base defaults to http.DefaultTransport if nil.
+// CloseIdleConnections is forwarded to base when it supports it.
+// Use for instance:
+//
+//	client := &http.Client{Transport: log.Transport(nil, "my client")}
+func Transport(base http.RoundTripper, msg string) http.RoundTripper {
+	return TransportWithOptions(nil, base, msg)
+}
+
+// TransportWithOptions is Transport using the given options instead of HTTPLogConfig
+// (which is used, at request time, if opts is nil).
+func TransportWithOptions(opts *HTTPLogOptions, base http.RoundTripper, msg string) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &loggingTransport{base: base, msg: msg, opts: opts}
+}
+
+type loggingTransport struct {
+	base http.RoundTripper
+	msg  string
+	opts *HTTPLogOptions
+}
+
+// clientTimings records the httptrace events, with a mutex as some (e.g. dialing) can happen
+// on other goroutines.
+type clientTimings struct {
+	mu           sync.Mutex
+	start        time.Time
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	firstByte    time.Time
+	gotConn      bool
+	reused       bool
+}
+
+func (ct *clientTimings) set(t *time.Time, onlyFirst bool) {
+	ct.mu.Lock()
+	if !onlyFirst || t.IsZero() {
+		*t = time.Now()
+	}
+	ct.mu.Unlock()
+}
+
+func (ct *clientTimings) trace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart:          func(httptrace.DNSStartInfo) { ct.set(&ct.dnsStart, true) },
+		DNSDone:           func(httptrace.DNSDoneInfo) { ct.set(&ct.dnsDone, false) },
+		ConnectStart:      func(_, _ string) { ct.set(&ct.connectStart, true) },
+		ConnectDone:       func(_, _ string, _ error) { ct.set(&ct.connectDone, false) },
+		TLSHandshakeStart: func() { ct.set(&ct.tlsStart, true) },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { ct.set(&ct.tlsDone, false) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			ct.mu.Lock()
+			ct.gotConn = true
+			ct.reused = info.Reused
+			ct.mu.Unlock()
+		},
+		GotFirstResponseByte: func() { ct.set(&ct.firstByte, true) },
+	}
+}
+
+// appendAttrs adds the microsec and phase timing attributes.
+func (ct *clientTimings) appendAttrs(attrs []KeyVal) []KeyVal {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	attrs = append(attrs, Int64("microsec", time.Since(ct.start).Microseconds()))
+	phase := func(key string, start, end time.Time) {
+		if !start.IsZero() && !end.IsZero() {
+			attrs = append(attrs, Int64(key, end.Sub(start).Microseconds()))
+		}
+	}
+	phase("dns_microsec", ct.dnsStart, ct.dnsDone)
+	phase("connect_microsec", ct.connectStart, ct.connectDone)
+	phase("tls_microsec", ct.tlsStart, ct.tlsDone)
+	phase("ttfb_microsec", ct.start, ct.firstByte)
+	if ct.gotConn {
+		attrs = append(attrs, Bool("reused", ct.reused))
+	}
+	return attrs
+}
+
+// CloseIdleConnections forwards to the base transport (if it supports it) so http.Client.CloseIdleConnections
+// works through the logging transport.
+func (t *loggingTransport) CloseIdleConnections() {
+	type closeIdler interface{ CloseIdleConnections() }
+	if ci, ok := t.base.(closeIdler); ok {
+		ci.CloseIdleConnections()
+	}
+}
+
+func (t *loggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	opts := t.opts
+	if opts == nil {
+		opts = HTTPLogConfig
+	}
+	if !Log(Error) {
+		return t.base.RoundTrip(req)
+	}
+	combined := Config.CombineRequestAndResponse
+	if !combined {
+		LogRequestWithOptions(opts, req, t.msg)
+	}
+	ct := &clientTimings{start: time.Now()}
+	// The RoundTripper contract is to not modify the request, WithContext makes a shallow copy.
+	resp, err := t.base.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), ct.trace())))
+	if err != nil {
+		attr := ct.appendAttrs([]KeyVal{Int("status", 0), Int64("size", 0)})
+		attr = append(attr, Err(err))
+		if combined {
+			logRequest(Error, opts, req, t.msg, attr...)
+		} else {
+			s(Error, false, Config.JSON, t.msg, attr...)
+		}
+		return resp, err
+	}
+	if !Log(Info) { // failures above are logged even when the level is Warning or Error.
+		return resp, nil
+	}
+	if combined {
+		attr := []KeyVal{Int("status", resp.StatusCode), Int64("size", resp.ContentLength)}
+		attr = ct.appendAttrs(attr)
+		attr = opts.ResponseHeaders.appendHeaders(attr, "resp.header.", resp.Header)
+		LogRequestWithOptions(opts, req, t.msg, attr...)
+	} else {
+		LogResponseWithOptions(opts, resp, t.msg, ct.appendAttrs(nil)...)
+	}
+	return resp, nil
+}
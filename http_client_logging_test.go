@@ -0,0 +1,129 @@
+// Copyright 2026 Fortio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !no_http && !no_net
+
+package log // import "fortio.org/log"
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestTransport(t *testing.T) {
+	SetLogLevelQuiet(Info)
+	Config.LogFileAndLine = false
+	Config.JSON = true
+	Config.NoTimestamp = true
+	Config.GoroutineID = false
+	Config.CombineRequestAndResponse = true
+	var b bytes.Buffer
+	var mu sync.Mutex
+	SetOutput(writerFunc(func(p []byte) (int, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return b.Write(p)
+	}))
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("hello"))
+	}))
+	client := srv.Client()
+	client.Transport = Transport(client.Transport, "client")
+	get := func() {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/foo?token=x", nil)
+		req.Header.Set("User-Agent", "test-ua")
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+	get()
+	Config.CombineRequestAndResponse = false
+	get()
+	srv.Close()
+	Config.CombineRequestAndResponse = true
+	_, err := client.Get(srv.URL)
+	if err == nil {
+		t.Errorf("expected error for closed server")
+	}
+	mu.Lock()
+	actual := regexp.MustCompile(`"(\w*)microsec":\d+`).ReplaceAllString(b.String(), `"${1}microsec":0`)
+	mu.Unlock()
+	host := strings.TrimPrefix(srv.URL, "https://")
+	//nolint: lll // long lines in expected.
+	expected := `{"level":"info","msg":"client","method":"GET","url":"` + srv.URL + `/foo?token=x","host":"` + host + `","proto":"HTTP/1.1","remote_addr":"","user-agent":"test-ua","status":200,"size":5,"microsec":0,"connect_microsec":0,"tls_microsec":0,"ttfb_microsec":0,"reused":false,"resp.header.content-type":"text/plain"}
+{"level":"info","msg":"client","method":"GET","url":"` + srv.URL + `/foo?token=x","host":"` + host + `","proto":"HTTP/1.1","remote_addr":"","user-agent":"test-ua"}
+{"level":"info","msg":"client","status":200,"size":5,"resp.header.content-type":"text/plain","microsec":0,"ttfb_microsec":0,"reused":true}
+`
+	if !strings.HasPrefix(actual, expected) {
+		t.Errorf("unexpected:\n%s\nvs should start with:\n%s\n", actual, expected)
+	}
+	if !strings.Contains(actual, `{"level":"err","msg":"client","method":"GET","url":"`+srv.URL+`"`) ||
+		!strings.Contains(actual, `"status":0,"size":0,"microsec":0,`) || !strings.Contains(actual, `"err":"dial tcp`) {
+		t.Errorf("missing error entry in %s", actual)
+	}
+	// Failures are still logged when Info isn't.
+	SetLogLevelQuiet(Warning)
+	mu.Lock()
+	b.Reset()
+	mu.Unlock()
+	_, err = client.Get(srv.URL)
+	if err == nil {
+		t.Errorf("expected error for closed server")
+	}
+	mu.Lock()
+	actual = b.String()
+	mu.Unlock()
+	if !strings.HasPrefix(actual, `{"level":"err","msg":"client","method":"GET"`) || strings.Count(actual, "\n") != 1 {
+		t.Errorf("expected only the error entry at Warning level, got %s", actual)
+	}
+	SetLogLevelQuiet(Info)
+	Config.GoroutineID = true
+}
+
+type closeIdleTransport struct {
+	http.RoundTripper
+	closed int
+}
+
+func (c *closeIdleTransport) CloseIdleConnections() {
+	c.closed++
+}
+
+func TestTransportCloseIdleConnections(t *testing.T) {
+	base := &closeIdleTransport{RoundTripper: http.DefaultTransport}
+	client := &http.Client{Transport: Transport(base, "client")}
+	client.CloseIdleConnections()
+	if base.closed != 1 {
+		t.Errorf("expected CloseIdleConnections to be forwarded, got %d calls", base.closed)
+	}
+	// No panic when the base doesn't support it.
+	client = &http.Client{Transport: Transport(eofRoundTripper{}, "client")}
+	client.CloseIdleConnections()
+}
+
+type eofRoundTripper struct{}
+
+func (eofRoundTripper) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, io.EOF
+}
@@ -0,0 +1,113 @@
+// Copyright 2026 Fortio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !no_http && !no_net
+
+// W3C trace context, request id correlation and per request log level/buffering for LogAndCall.
+
+package log // import "fortio.org/log"
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// maxRequestIDLen is the longest incoming request id we accept (longer ones get replaced by a generated one).
+const maxRequestIDLen = 128
+
+// NewRequestID returns a new random request id (32 hex characters).
+func NewRequestID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// isHex returns true if s is non empty and only lowercase hex digits, with at least one non zero digit
+// when nonZero is set.
+func isHex(s string, nonZero bool) bool {
+	if s == "" {
+		return false
+	}
+	zero := true
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+		if c != '0' {
+			zero = false
+		}
+	}
+	return !nonZero || !zero
+}
+
+// ParseTraceParent parses a W3C trace context traceparent header value
+// ("version-traceid-parentid-flags", e.g. "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+// and returns the trace id and parent span id, ok is false if the value is invalid.
+func ParseTraceParent(value string) (traceID, spanID string, ok bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || !isHex(parts[0], false) || parts[0] == "ff" ||
+		(parts[0] == "00" && len(parts) != 4) ||
+		len(parts[1]) != 32 || !isHex(parts[1], true) ||
+		len(parts[2]) != 16 || !isHex(parts[2], true) ||
+		len(parts[3]) != 2 || !isHex(parts[3], false) {
+		return "", "", false
+	}
+	return parts[1], parts[2], true
+}
+
+// correlationAttrs returns the trace_id, span_id, tracestate and req_id attributes for the request,
+// per the TraceContext and RequestIDHeader options, and echoes the request id in the response headers.
+func (opts *HTTPLogOptions) correlationAttrs(w http.ResponseWriter, r *http.Request) []KeyVal {
+	var attrs []KeyVal
+	if opts.TraceContext {
+		if traceID, spanID, ok := ParseTraceParent(r.Header.Get("Traceparent")); ok {
+			attrs = append(attrs, Str("trace_id", traceID), Str("span_id", spanID))
+			if state := r.Header.Values("Tracestate"); len(state) > 0 {
+				attrs = append(attrs, Str("tracestate", strings.Join(state, ",")))
+			}
+		}
+	}
+	if opts.RequestIDHeader != "" {
+		id := r.Header.Get(opts.RequestIDHeader)
+		if id == "" || len(id) > maxRequestIDLen {
+			id = NewRequestID()
+		}
+		w.Header().Set(opts.RequestIDHeader, id)
+		attrs = append(attrs, Str("req_id", id))
+	}
+	return attrs
+}
+
+// withLogState returns the request with, in its context, the log level from the LogLevelHeader header
+// (if valid and authorized) and/or a buffer per RequestLogBufferSize. The buffer is nil if not buffering.
+func (opts *HTTPLogOptions) withLogState(r *http.Request) (*http.Request, *logBuffer) {
+	st := &requestLogState{}
+	if opts.LogLevelHeader != "" && opts.LogLevelAuthorize != nil {
+		if value := r.Header.Get(opts.LogLevelHeader); value != "" && opts.LogLevelAuthorize(r) {
+			lvl, err := ValidateLevel(strings.ToLower(strings.TrimSpace(value)))
+			st.hasLevel = err == nil
+			st.level = lvl
+		}
+	}
+	if opts.RequestLogBufferSize > 0 {
+		st.buf = newLogBuffer(opts.RequestLogBufferSize)
+	}
+	if !st.hasLevel && st.buf == nil {
+		return r, nil
+	}
+	return r.WithContext(withLogState(r.Context(), st)), st.buf
+}
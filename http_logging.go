@@ -17,13 +17,18 @@
 package log
 
 import (
+	"bufio"
 	"crypto/tls"
 	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
-	"runtime/debug"
+	"net/url"
+	"runtime"
 	"sort"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -59,13 +64,200 @@ func AddIfNotEmpty(attrs []KeyVal, key, value string) []KeyVal {
 	return attrs
 }
 
+// HeaderLevel is a header (case-insensitive name) to log when Level is enabled.
+type HeaderLevel struct {
+	Name  string
+	Level Level
+}
+
+// HeaderRules selects which http headers are logged, and at which level.
+type HeaderRules struct {
+	// Headers logged (in that order, first value only) when their level is enabled and All isn't.
+	Headers []HeaderLevel
+	// When this level is enabled, all the headers (but the Deny ones) are logged, sorted, with
+	// all their values joined with ','. Use NoLevel to never log all of them.
+	All Level
+	// Headers never logged (takes precedence over Headers and All).
+	Deny []string
+}
+
+// HTTPLogOptions configures the http logging functions (LogRequest, LogResponse, LogAndCall).
+// See [HTTPLogConfig] for the global default and the ...WithOptions variants for specific handlers.
+type HTTPLogOptions struct {
+	RequestHeaders  HeaderRules
+	ResponseHeaders HeaderRules
+	// Query parameters whose values are replaced by [Redacted] in the "url" attribute (case-insensitive).
+	RedactQueryParams []string
+	// Used by LogAndCall: when set (the default), the W3C traceparent (and tracestate) request headers are
+	// parsed and logged as trace_id and span_id (and tracestate).
+	TraceContext bool
+	// Used by LogAndCall: when set (e.g. "X-Request-Id"), the request id from that header, or a generated one
+	// if missing, is logged as req_id and echoed in the response headers. It's off by default as, unlike
+	// TraceContext, it changes the responses and adds an attribute to every request entry.
+	// These ids are also added to the request's context for downstream SCtx() calls (see [WithAttrs]).
+	RequestIDHeader string
+	// Used by LogAndCall: when > 0, the request's SCtx() entries below the current log level are kept in memory
+	// (up to this many bytes) and only written if the response status is >= 500 or the handler panics.
+	// They are written when the handler returns so they appear after the entries of that request which
+	// were written directly (e.g. its Info ones), in their own order and with their original timestamps.
+	RequestLogBufferSize int
+	// Used by LogAndCall: name of a request header (e.g. "X-Log-Level") whose value, a level name like "debug"
+	// (case-insensitive), lowers the level of the request's SCtx() entries. The header is only used for the
+	// requests for which LogLevelAuthorize returns true.
+	LogLevelHeader string
+	// Decides if the LogLevelHeader of a request is trusted (e.g. checks a token or the client address), like
+	// AdminHandler's authorize. The header is ignored if nil.
+	LogLevelAuthorize func(r *http.Request) bool
+	// Maximum number of stack frames logged (at Verbose) when a handler panics, 0 for all of them.
+	PanicStackDepth int
+	// If true, the runtime package frames (e.g. runtime.gopanic) are omitted from the panic stack traces.
+	PanicSkipRuntimeFrames bool
+	// Handler panics with http.ErrAbortHandler (used to abort a response on purpose) are, by default, not logged
+	// as errors but re-panicked, after logging the request, so net/http aborts the connection. If true, they are
+	// instead recovered, logged and answered like the other panics.
+	SwallowAbortHandler bool
+	// When set, LogAndCall writes an Apache style access log line per request to this writer (e.g. a separate file),
+	// formatted according to AccessLogFormat, instead of the structured request and response entries.
+	AccessLog io.Writer
+	// Apache style template for the AccessLog lines, [AccessLogCombined] if empty (see also [AccessLogCommon]).
+	AccessLogFormat string
+}
+
+// DefaultHTTPLogOptions returns the default http logging options: at Info the user-agent and
+// X-Forwarded-Proto/For/Host request headers and the response Content-Type, at Verbose all the request
+// headers except the credentials ones (Authorization, Proxy-Authorization and Cookie, and Set-Cookie
+// for the responses) which are never logged even when redaction is off. W3C TraceContext correlation is on.
+func DefaultHTTPLogOptions() *HTTPLogOptions {
+	return &HTTPLogOptions{
+		RequestHeaders: HeaderRules{
+			Headers: []HeaderLevel{
+				{"User-Agent", Info},
+				{"X-Forwarded-Proto", Info},
+				{"X-Forwarded-For", Info},
+				{"X-Forwarded-Host", Info},
+			},
+			All:  Verbose,
+			Deny: []string{"Authorization", "Proxy-Authorization", "Cookie"},
+		},
+		ResponseHeaders: HeaderRules{
+			Headers: []HeaderLevel{{"Content-Type", Info}},
+			All:     NoLevel,
+			Deny:    []string{"Set-Cookie"},
+		},
+		TraceContext: true,
+	}
+}
+
+// HTTPLogConfig are the options used by LogRequest, LogResponse and LogAndCall.
+// It is read at each request so it can be changed without rewiring the middleware.
+var HTTPLogConfig = DefaultHTTPLogOptions()
+
+func (h *HeaderRules) denied(name string) bool {
+	for _, d := range h.Deny {
+		if strings.EqualFold(d, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// headerKey is the attribute name for the header name (lowercase with prefix, except for user-agent
+// for the request headers).
+func headerKey(prefix, name string) string {
+	nl := strings.ToLower(name)
+	if prefix == "header." && nl == "user-agent" {
+		return nl
+	}
+	return prefix + nl
+}
+
+// selectedHeaders appends the selected headers (when All isn't enabled).
+func (h *HeaderRules) selectedHeaders(attrs []KeyVal, prefix string, headers http.Header) []KeyVal {
+	if h.All != NoLevel && Log(h.All) {
+		return attrs
+	}
+	for _, hl := range h.Headers {
+		if !Log(hl.Level) || h.denied(hl.Name) {
+			continue
+		}
+		attrs = AddIfNotEmpty(attrs, headerKey(prefix, hl.Name), headers.Get(hl.Name))
+	}
+	return attrs
+}
+
+// allHeaders appends all the (non denied) headers when All is enabled.
+func (h *HeaderRules) allHeaders(attrs []KeyVal, prefix string, headers http.Header) []KeyVal {
+	if h.All == NoLevel || !Log(h.All) {
+		return attrs
+	}
+	// Need to sort to get a consistent order
+	keys := make([]string, 0, len(headers))
+	for name := range headers {
+		if !h.denied(name) {
+			keys = append(keys, name)
+		}
+	}
+	sort.Strings(keys)
+	for _, name := range keys {
+		attrs = append(attrs, Str(headerKey(prefix, name), strings.Join(headers[name], ",")))
+	}
+	return attrs
+}
+
+// appendHeaders appends the headers selected by the rules (both selected and all).
+func (h *HeaderRules) appendHeaders(attrs []KeyVal, prefix string, headers http.Header) []KeyVal {
+	attrs = h.selectedHeaders(attrs, prefix, headers)
+	return h.allHeaders(attrs, prefix, headers)
+}
+
+// redactQuery returns the url string with the values of the params query parameters redacted.
+func redactQuery(u *url.URL, params []string) string {
+	if len(params) == 0 || u.RawQuery == "" {
+		return u.String()
+	}
+	parts := strings.Split(u.RawQuery, "&")
+	changed := false
+	for i, part := range parts {
+		rawName, _, _ := strings.Cut(part, "=")
+		name := rawName
+		if uname, err := url.QueryUnescape(rawName); err == nil {
+			name = uname
+		}
+		for _, p := range params {
+			if strings.EqualFold(p, name) {
+				parts[i] = rawName + "=" + Redacted
+				changed = true
+				break
+			}
+		}
+	}
+	if !changed {
+		return u.String()
+	}
+	redacted := *u
+	redacted.RawQuery = strings.Join(parts, "&")
+	return redacted.String()
+}
+
 // LogRequest logs the incoming request, TLSInfo,
-// including headers when loglevel is verbose.
+// including headers when loglevel is verbose (see [HTTPLogConfig] to change which ones).
 // additional key:value pairs can be passed as extraAttributes.
 //
 //nolint:revive // name is fine.
 func LogRequest(r *http.Request, msg string, extraAttributes ...KeyVal) {
-	if !Log(Info) {
+	LogRequestWithOptions(HTTPLogConfig, r, msg, extraAttributes...)
+}
+
+// LogRequestWithOptions is LogRequest using the given options instead of HTTPLogConfig.
+//
+//nolint:revive // name is fine.
+func LogRequestWithOptions(opts *HTTPLogOptions, r *http.Request, msg string, extraAttributes ...KeyVal) {
+	logRequest(Info, opts, r, msg, extraAttributes...)
+}
+
+// logRequest is LogRequestWithOptions at the given level.
+func logRequest(lvl Level, opts *HTTPLogOptions, r *http.Request, msg string, extraAttributes ...KeyVal) {
+	if !Log(lvl) {
 		return
 	}
 	// URL struct is quite verbose and not that interesting to log all pieces so we log the String() version
@@ -73,39 +265,20 @@ func LogRequest(r *http.Request, msg string, extraAttributes ...KeyVal) {
 	if r.URL == nil {
 		url = Any("url", r.URL) // basically 'null'
 	} else {
-		url = Str("url", r.URL.String())
+		url = Str("url", redactQuery(r.URL, opts.RedactQueryParams))
 	}
 	attr := []KeyVal{
 		Str("method", r.Method), url, Str("host", r.Host),
 		Str("proto", r.Proto), Str("remote_addr", r.RemoteAddr),
 	}
-	if !LogVerbose() { // in verbose all headers are already logged
-		attr = AddIfNotEmpty(attr, "user-agent", r.Header.Get("User-Agent"))
-		// note this only prints the first one, while verbose mode will join all values with ','
-		attr = AddIfNotEmpty(attr, "header.x-forwarded-proto", r.Header.Get("X-Forwarded-Proto"))
-		attr = AddIfNotEmpty(attr, "header.x-forwarded-for", r.Header.Get("X-Forwarded-For"))
-		attr = AddIfNotEmpty(attr, "header.x-forwarded-host", r.Header.Get("X-Forwarded-Host"))
-	}
+	// note this only prints the first one, while verbose (All) mode will join all values with ','
+	attr = opts.RequestHeaders.selectedHeaders(attr, "header.", r.Header)
 	attr = AppendTLSInfoAttrs(attr, r)
 	attr = append(attr, extraAttributes...)
-	if LogVerbose() {
-		// Host is removed from headers map and put separately
-		// Need to sort to get a consistent order
-		keys := make([]string, 0, len(r.Header))
-		for name := range r.Header {
-			keys = append(keys, name)
-		}
-		sort.Strings(keys)
-		for _, name := range keys {
-			nl := strings.ToLower(name)
-			if nl != "user-agent" {
-				nl = "header." + nl
-			}
-			attr = append(attr, Str(nl, strings.Join(r.Header[name], ",")))
-		}
-	}
+	// Host is removed from headers map and put separately
+	attr = opts.RequestHeaders.allHeaders(attr, "header.", r.Header)
 	// not point in having the line number be this file
-	s(Info, false, Config.JSON, msg, attr...)
+	s(lvl, false, Config.JSON, msg, attr...)
 }
 
 // LogResponse logs the response code, byte size and duration of the request.
@@ -113,23 +286,42 @@ func LogRequest(r *http.Request, msg string, extraAttributes ...KeyVal) {
 //
 //nolint:revive // name is fine.
 func LogResponse[T *ResponseRecorder | *http.Response](r T, msg string, extraAttributes ...KeyVal) {
+	LogResponseWithOptions(HTTPLogConfig, r, msg, extraAttributes...)
+}
+
+// LogResponseWithOptions is LogResponse using the given options (for the response headers)
+// instead of HTTPLogConfig.
+//
+//nolint:revive // name is fine.
+func LogResponseWithOptions[T *ResponseRecorder | *http.Response](opts *HTTPLogOptions, r T, msg string,
+	extraAttributes ...KeyVal,
+) {
 	if !Log(Info) {
 		return
 	}
 	var status int
 	var size int64
+	var headers http.Header
+	var rr *ResponseRecorder
 	switch v := any(r).(type) { // go generics...
 	case *ResponseRecorder:
 		status = v.StatusCode
 		size = v.ContentLength
+		headers = v.Header()
+		rr = v
 	case *http.Response:
 		status = v.StatusCode
 		size = v.ContentLength
+		headers = v.Header
 	}
 	attr := []KeyVal{
 		Int("status", status),
 		Int64("size", size),
 	}
+	if rr != nil {
+		attr = rr.appendRecordedAttrs(attr)
+	}
+	attr = opts.ResponseHeaders.appendHeaders(attr, "resp.header.", headers)
 	attr = append(attr, extraAttributes...)
 	// not point in having the line number be this file
 	s(Info, false, Config.JSON, msg, attr...)
@@ -141,6 +333,61 @@ type ResponseRecorder struct {
 	startTime     time.Time
 	StatusCode    int
 	ContentLength int64
+	// True if the connection was hijacked (e.g. websocket upgrade), see HijackedBytes().
+	// The status of hijacked connections isn't known (StatusCode is 0 unless set before hijacking).
+	Hijacked      bool
+	hijackedBytes int64 // atomic as the hijacked connection can be used from other goroutines.
+	// True if the request context was canceled (typically the client went away) before the handler returned.
+	ClientGone bool
+	firstByte  time.Time     // first WriteHeader/Write (or hijack), for time to first byte.
+	body       *countingBody // request body (if any) wrapped to count the bytes read.
+}
+
+// countingBody counts the bytes read from the request body.
+type countingBody struct {
+	io.ReadCloser
+	n int64 // atomic
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	atomic.AddInt64(&b.n, int64(n))
+	return n, err
+}
+
+// newResponseRecorder wraps w and the request body.
+func newResponseRecorder(w http.ResponseWriter, r *http.Request) *ResponseRecorder {
+	rr := &ResponseRecorder{w: w, startTime: time.Now()}
+	if r.Body != nil && r.Body != http.NoBody {
+		rr.body = &countingBody{ReadCloser: r.Body}
+		r.Body = rr.body
+	}
+	return rr
+}
+
+// markFirstByte records the time to first byte, if not already set.
+func (rr *ResponseRecorder) markFirstByte() {
+	if rr.firstByte.IsZero() {
+		rr.firstByte = time.Now()
+	}
+}
+
+// TimeToFirstByte returns the duration between the start of the request and the first WriteHeader() or Write()
+// (or hijacking), 0 if nothing was written (yet).
+func (rr *ResponseRecorder) TimeToFirstByte() time.Duration {
+	if rr.firstByte.IsZero() {
+		return 0
+	}
+	return rr.firstByte.Sub(rr.startTime)
+}
+
+// BodyBytesRead returns how many bytes of the request body were read by the handler, -1 if the
+// request had no body (or the recorder wasn't created by LogAndCall).
+func (rr *ResponseRecorder) BodyBytesRead() int64 {
+	if rr.body == nil {
+		return -1
+	}
+	return atomic.LoadInt64(&rr.body.n)
 }
 
 func (rr *ResponseRecorder) Header() http.Header {
@@ -148,6 +395,7 @@ func (rr *ResponseRecorder) Header() http.Header {
 }
 
 func (rr *ResponseRecorder) Write(p []byte) (int, error) {
+	rr.markFirstByte()
 	size, err := rr.w.Write(p)
 	rr.ContentLength += int64(size)
 	if err != nil {
@@ -159,6 +407,7 @@ func (rr *ResponseRecorder) Write(p []byte) (int, error) {
 }
 
 func (rr *ResponseRecorder) WriteHeader(code int) {
+	rr.markFirstByte()
 	rr.w.WriteHeader(code)
 	rr.StatusCode = code
 }
@@ -170,71 +419,327 @@ func (rr *ResponseRecorder) Flush() {
 	}
 }
 
+// Unwrap returns the wrapped http.ResponseWriter, this is what http.ResponseController
+// uses to find the optional methods (e.g. SetReadDeadline, SetWriteDeadline, EnableFullDuplex).
+func (rr *ResponseRecorder) Unwrap() http.ResponseWriter {
+	return rr.w
+}
+
+// HijackedBytes returns the number of bytes written to the connection after it got hijacked.
+func (rr *ResponseRecorder) HijackedBytes() int64 {
+	return atomic.LoadInt64(&rr.hijackedBytes)
+}
+
+// failed returns true for 5xx responses and panics (negative status).
+func (rr *ResponseRecorder) failed() bool {
+	return rr.StatusCode >= 500 || rr.StatusCode < 0
+}
+
+// appendRecordedAttrs adds, when applicable, the ttfb_microsec (time to first byte), bytes_read
+// (of the request body), client_gone, hijacked and hijacked_bytes attributes.
+func (rr *ResponseRecorder) appendRecordedAttrs(attrs []KeyVal) []KeyVal {
+	if !rr.firstByte.IsZero() {
+		attrs = append(attrs, Int64("ttfb_microsec", rr.TimeToFirstByte().Microseconds()))
+	}
+	if rr.body != nil {
+		attrs = append(attrs, Int64("bytes_read", rr.BodyBytesRead()))
+	}
+	if rr.ClientGone {
+		attrs = append(attrs, Bool("client_gone", true))
+	}
+	if rr.Hijacked {
+		attrs = append(attrs, Bool("hijacked", true), Int64("hijacked_bytes", rr.HijackedBytes()))
+	}
+	return attrs
+}
+
+// hijackedConn counts the bytes written to a hijacked connection.
+type hijackedConn struct {
+	net.Conn
+	rr *ResponseRecorder
+}
+
+func (c hijackedConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	atomic.AddInt64(&c.rr.hijackedBytes, int64(n))
+	return n, err
+}
+
+func (rr *ResponseRecorder) hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := rr.w.(http.Hijacker).Hijack()
+	if err != nil {
+		return conn, brw, err
+	}
+	rr.Hijacked = true
+	rr.markFirstByte()
+	// The hijacker writes its own response (if any) which we don't parse, so StatusCode stays
+	// as is (typically 0) and the hijacked attribute is the marker instead.
+	cc := hijackedConn{conn, rr}
+	if brw != nil && brw.Writer.Buffered() == 0 {
+		brw = bufio.NewReadWriter(brw.Reader, bufio.NewWriterSize(cc, brw.Writer.Size()))
+	}
+	return cc, brw, nil
+}
+
+func (rr *ResponseRecorder) push(target string, opts *http.PushOptions) error {
+	return rr.w.(http.Pusher).Push(target, opts)
+}
+
+func (rr *ResponseRecorder) readFrom(src io.Reader) (int64, error) {
+	rr.markFirstByte()
+	size, err := rr.w.(io.ReaderFrom).ReadFrom(src)
+	rr.ContentLength += size
+	if err != nil {
+		rr.StatusCode = http.StatusInternalServerError
+	} else if rr.StatusCode == 0 {
+		rr.StatusCode = http.StatusOK
+	}
+	return size, err
+}
+
+// Small types to add (only) the optional interfaces the wrapped writer supports, see ResponseWriter().
+type (
+	rrHijacker   struct{ rr *ResponseRecorder }
+	rrPusher     struct{ rr *ResponseRecorder }
+	rrReaderFrom struct{ rr *ResponseRecorder }
+)
+
+func (h rrHijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return h.rr.hijack()
+}
+
+func (p rrPusher) Push(target string, opts *http.PushOptions) error {
+	return p.rr.push(target, opts)
+}
+
+func (r rrReaderFrom) ReadFrom(src io.Reader) (int64, error) {
+	return r.rr.readFrom(src)
+}
+
+// ResponseWriter returns the http.ResponseWriter to pass to handlers: the recorder itself
+// (which also implements http.Flusher and Unwrap()) plus, transparently, the http.Hijacker,
+// http.Pusher and io.ReaderFrom interfaces if (and only if) the wrapped writer implements them.
+func (rr *ResponseRecorder) ResponseWriter() http.ResponseWriter {
+	_, h := rr.w.(http.Hijacker)
+	_, p := rr.w.(http.Pusher)
+	_, rf := rr.w.(io.ReaderFrom)
+	switch {
+	case h && p && rf:
+		return struct {
+			*ResponseRecorder
+			rrHijacker
+			rrPusher
+			rrReaderFrom
+		}{rr, rrHijacker{rr}, rrPusher{rr}, rrReaderFrom{rr}}
+	case h && p:
+		return struct {
+			*ResponseRecorder
+			rrHijacker
+			rrPusher
+		}{rr, rrHijacker{rr}, rrPusher{rr}}
+	case h && rf:
+		return struct {
+			*ResponseRecorder
+			rrHijacker
+			rrReaderFrom
+		}{rr, rrHijacker{rr}, rrReaderFrom{rr}}
+	case p && rf:
+		return struct {
+			*ResponseRecorder
+			rrPusher
+			rrReaderFrom
+		}{rr, rrPusher{rr}, rrReaderFrom{rr}}
+	case h:
+		return struct {
+			*ResponseRecorder
+			rrHijacker
+		}{rr, rrHijacker{rr}}
+	case p:
+		return struct {
+			*ResponseRecorder
+			rrPusher
+		}{rr, rrPusher{rr}}
+	case rf:
+		return struct {
+			*ResponseRecorder
+			rrReaderFrom
+		}{rr, rrReaderFrom{rr}}
+	default:
+		return rr
+	}
+}
+
 // LogAndCall logs the incoming request and the response code, byte size and duration
 // of the request.
 //
 // If Config.CombineRequestAndResponse or the LOGGER_COMBINE_REQUEST_AND_RESPONSE
 // environment variable is true, then a single log entry is done combining request and
-// response information, including catching for panic.
+// response information.
+//
+// In both modes panics in the handler are caught: logged at Critical (with the stack trace at Verbose,
+// see HTTPLogOptions.PanicStackDepth), answered with a 500 error if nothing was written yet, and the
+// logged status is -500. The http.ErrAbortHandler panics are re-panicked, after logging the request,
+// unless HTTPLogOptions.SwallowAbortHandler is set.
 //
 // Additional key:value pairs can be passed as extraAttributes.
 //
 //nolint:revive // name is fine.
 func LogAndCall(msg string, handlerFunc http.HandlerFunc, extraAttributes ...KeyVal) http.HandlerFunc {
+	return LogAndCallWithOptions(nil, msg, handlerFunc, extraAttributes...)
+}
+
+// LogAndCallWithOptions is LogAndCall using the given options, nil opts means
+// using [HTTPLogConfig] (as of each request).
+//
+//nolint:revive // name is fine.
+func LogAndCallWithOptions(opts *HTTPLogOptions, msg string, handlerFunc http.HandlerFunc,
+	extraAttributes ...KeyVal,
+) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		opts := opts
+		if opts == nil {
+			opts = HTTPLogConfig
+		}
+		extra := extraAttributes
+		ids := opts.correlationAttrs(w, r)
+		if len(ids) > 0 {
+			r = r.WithContext(WithAttrs(r.Context(), ids...))
+			extra = append(ids[:len(ids):len(ids)], extraAttributes...)
+		}
+		r, reqBuf := opts.withLogState(r)
 		// This is really 2 functions but we want to be able to change config without rewiring the middleware
-		if Config.CombineRequestAndResponse { //nolint:nestif // see above comment.
-			respRec := &ResponseRecorder{w: w, startTime: time.Now()}
-			defer func() {
-				if err := recover(); err != nil {
-					s(Critical, false, Config.JSON, "panic in handler", Any("error", err))
-					if Log(Verbose) {
-						s(Verbose, false, Config.JSON, "stack trace", Str("stack", string(debug.Stack())))
-					}
-					respRec.StatusCode = -500       // Marking as a panic for the log.
-					if respRec.ContentLength == 0 { // Nothing was written yet so we can write an error
-						http.Error(w, fmt.Sprintf("Internal Server Error\n%s", err), http.StatusInternalServerError)
-					}
-				}
+		combined := Config.CombineRequestAndResponse
+		if !combined && opts.AccessLog == nil {
+			LogRequestWithOptions(opts, r, msg, extra...)
+		}
+		respRec := newResponseRecorder(w, r)
+		defer func() {
+			var repanic any
+			if err := recover(); err != nil {
+				repanic = opts.handlePanic(err, w, respRec)
+			}
+			respRec.ClientGone = r.Context().Err() != nil
+			reqBuf.finish(respRec.failed())
+			switch {
+			case opts.AccessLog != nil:
+				opts.writeAccessLog(r, respRec)
+			case combined:
 				attr := []KeyVal{
 					Int("status", respRec.StatusCode),
 					Int64("size", respRec.ContentLength),
 					Int64("microsec", time.Since(respRec.startTime).Microseconds()),
 				}
-				attr = append(attr, extraAttributes...)
-				LogRequest(r, msg, attr...)
-			}()
-			handlerFunc(respRec, r)
-			return
-		}
-		LogRequest(r, msg, extraAttributes...)
-		respRec := &ResponseRecorder{w: w, startTime: time.Now()}
-		handlerFunc(respRec, r)
-		LogResponse(respRec, msg, Int64("microsec", time.Since(respRec.startTime).Microseconds()))
+				attr = respRec.appendRecordedAttrs(attr)
+				attr = opts.ResponseHeaders.appendHeaders(attr, "resp.header.", respRec.Header())
+				attr = append(attr, extra...)
+				LogRequestWithOptions(opts, r, msg, attr...)
+			default:
+				attr := append(ids, Int64("microsec", time.Since(respRec.startTime).Microseconds()))
+				LogResponseWithOptions(opts, respRec, msg, attr...)
+			}
+			if repanic != nil {
+				panic(repanic)
+			}
+		}()
+		handlerFunc(respRec.ResponseWriter(), r)
 	})
 }
 
+// handlePanic logs the handler panic err (and its stack trace at Verbose), marks the response as a panic
+// (-500 status) and sends a 500 error if nothing was written yet. It returns err if it must be re-panicked
+// (http.ErrAbortHandler unless SwallowAbortHandler is set), nil otherwise.
+func (opts *HTTPLogOptions) handlePanic(err any, w http.ResponseWriter, respRec *ResponseRecorder) any {
+	// Marking as a panic for the log.
+	respRec.StatusCode = -500
+	if !opts.SwallowAbortHandler && err == http.ErrAbortHandler { //nolint:errorlint // the sentinel value is what's panicked.
+		return err // net/http doesn't log those either.
+	}
+	s(Critical, false, Config.JSON, "panic in handler", Any("error", err))
+	if Log(Verbose) {
+		s(Verbose, false, Config.JSON, "stack trace", Str("stack", opts.panicStack()))
+	}
+	if respRec.ContentLength == 0 && !respRec.Hijacked { // Nothing was written yet so we can write an error
+		http.Error(w, fmt.Sprintf("Internal Server Error\n%s", err), http.StatusInternalServerError)
+	}
+	return nil
+}
+
+// panicStack returns the stack trace of the panicking goroutine, starting at the panic, as function name
+// and file:line pairs, limited to PanicStackDepth frames and without the runtime frames if PanicSkipRuntimeFrames.
+// Must be called from handlePanic (for the frames to skip).
+func (opts *HTTPLogOptions) panicStack() string {
+	pcs := make([]uintptr, 256)
+	// skip runtime.Callers, panicStack, handlePanic and LogAndCall's deferred function.
+	n := runtime.Callers(4, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	var buf strings.Builder
+	count := 0
+	for {
+		frame, more := frames.Next()
+		if !opts.PanicSkipRuntimeFrames || !strings.HasPrefix(frame.Function, "runtime.") {
+			if opts.PanicStackDepth > 0 && count >= opts.PanicStackDepth {
+				buf.WriteString("...\n")
+				break
+			}
+			fmt.Fprintf(&buf, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+			count++
+		}
+		if !more {
+			break
+		}
+	}
+	return buf.String()
+}
+
 type logWriter struct {
-	source string
-	level  Level
+	source     string
+	level      Level
+	classifier LevelClassifier
 }
 
 // NewStdLogger returns a Std logger that will log to the given level with the given source attribute.
 // Can be passed for instance to net/http/httputil.ReverseProxy.ErrorLog.
 func NewStdLogger(source string, level Level) *log.Logger {
-	return log.New(logWriter{source, level}, "", 0)
+	return NewStdLoggerWithClassifier(source, level, nil)
+}
+
+// NewStdLoggerWithClassifier is NewStdLogger with the level of each message inferred by the
+// classifier (e.g. DefaultLevelClassifier), level being used when it can't.
+// Can be passed for instance to http.Server.ErrorLog.
+func NewStdLoggerWithClassifier(source string, level Level, classifier LevelClassifier) *log.Logger {
+	return log.New(logWriter{source, level, classifier}, "", 0)
 }
 
 func (w logWriter) Write(p []byte) (n int, err error) {
-	// Force JSON to avoid infinite loop and also skip file/line so it doesn't show this file as the source
-	// (TODO consider passing the level up the stack to look for the caller)
-	s(w.level, false, true, strings.TrimSpace(string(p)), Str("src", w.source))
+	msg := strings.TrimSpace(string(p))
+	lvl := w.level
+	if w.classifier != nil {
+		if inferred, stripped, ok := w.classifier(msg); ok {
+			lvl, msg = inferred, stripped
+		}
+	}
+	if !Log(lvl) {
+		return len(p), nil
+	}
+	file, line := "", 0
+	if Config.LogFileAndLine {
+		// skip the std log package and our frames so it doesn't show this file as the source.
+		file, line = callerOutsideLog()
+	}
+	// Force JSON to avoid infinite loop (text mode goes through the std logger).
+	emitAt(nil, lvl, file, line, true, msg, Str("src", w.source))
 	return len(p), nil
 }
 
 // InterceptStandardLogger changes the output of the standard logger to use ours, at the given
 // level, with the source "std", as a catchall.
 func InterceptStandardLogger(level Level) {
+	InterceptStandardLoggerWithClassifier(level, nil)
+}
+
+// InterceptStandardLoggerWithClassifier is InterceptStandardLogger with the level of each message
+// inferred by the classifier (e.g. DefaultLevelClassifier), level being used when it can't.
+func InterceptStandardLoggerWithClassifier(level Level, classifier LevelClassifier) {
 	log.SetFlags(0)
-	log.SetOutput(logWriter{"std", level})
+	log.SetOutput(logWriter{"std", level, classifier})
 }
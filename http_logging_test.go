@@ -5,14 +5,21 @@ package log // import "fortio.org/fortio/log"
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"errors"
-	"log"
+	"io"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"regexp"
+	"runtime"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -98,7 +105,8 @@ func TestLogAndCall(t *testing.T) {
 	hw := &ResponseRecorder{w: n}
 	LogAndCall("test-log-and-call", testHandler).ServeHTTP(hw, hr)
 	w.Flush()
-	actual := b.String()
+	ttfb := regexp.MustCompile(`,"ttfb_microsec":\d+`)
+	actual := ttfb.ReplaceAllString(b.String(), "")
 	//nolint: lll // long lines in expected.
 	expectedPrefix := `{"level":"info","msg":"test-log-and-call","method":"","url":null,"host":"","proto":"","remote_addr":"","header.x-forwarded-host":"foo2.fortio.org"}
 {"level":"info","msg":"test-log-and-call","status":200,"size":5,"microsec":1` // the 1 is for the 100ms sleep
@@ -123,7 +131,7 @@ func TestLogAndCall(t *testing.T) {
 	n.doErr = true
 	LogAndCall("test-log-and-call3", testHandler).ServeHTTP(hw, hr)
 	w.Flush()
-	actual = b.String()
+	actual = ttfb.ReplaceAllString(b.String(), "")
 	expectedFragment := `"header.x-forwarded-host":"foo2.fortio.org","status":500,"size":0,"microsec":1`
 	if !strings.Contains(actual, expectedFragment) {
 		t.Errorf("unexpected:\n%s\nvs should contain error:\n%s\n", actual, expectedFragment)
@@ -134,7 +142,7 @@ func TestLogAndCall(t *testing.T) {
 	b.Reset()
 	LogAndCall("test-log-and-call4", testHandler).ServeHTTP(hw, hr)
 	w.Flush()
-	actual = b.String()
+	actual = ttfb.ReplaceAllString(b.String(), "")
 	expectedFragment = `"status":-500,`
 	Config.GoroutineID = false
 	if !strings.Contains(actual, expectedFragment) {
@@ -147,7 +155,7 @@ func TestLogAndCall(t *testing.T) {
 	b.Reset()
 	LogAndCall("test-log-and-call5", testHandler).ServeHTTP(hw, hr)
 	w.Flush()
-	actual = b.String()
+	actual = ttfb.ReplaceAllString(b.String(), "")
 	expectedFragment = `"status":-500,`
 	Config.GoroutineID = false
 	if !strings.Contains(actual, expectedFragment) {
@@ -229,38 +237,531 @@ func TestTLSInfo(t *testing.T) {
 	}
 }
 
-func TestInterceptStandardLogger(t *testing.T) {
-	SetLogLevel(Warning)
+func TestNewStdLogger(t *testing.T) {
+	SetLogLevel(Info)
 	Config.LogFileAndLine = true
-	Config.JSON = false // check that despite this, it'll be json anyway (so it doesn't go infinite loop)
+	Config.JSON = false
 	Config.NoTimestamp = true
 	var b bytes.Buffer
 	w := bufio.NewWriter(&b)
 	SetOutput(w)
-	InterceptStandardLogger(Warning)
-	log.Printf("\n\na test\n\n")
+	Config.LogFileAndLine = false
+	logger := NewStdLogger("test src", Info)
+	logger.Printf("\n\nanother test\n\n")
+	logger.Printf("[E] not classified")
+	logger = NewStdLoggerWithClassifier("test src", Info, DefaultLevelClassifier)
+	logger.Printf("[E] classified")
+	logger.Printf("Warning: also classified")
+	logger.Printf("http: TLS handshake error from 10.0.0.1:1234: EOF")
+	logger.Printf("something failed")
+	logger.Printf("[D] below the level")
+	logger.Printf("just info")
+	logger.Printf("I/O timeout")
+	logger.Printf("E-mail sent")
+	logger.Printf("T-minus 10")
+	logger.Printf("WARN disk almost full")
 	w.Flush()
 	actual := b.String()
-	expected := `{"level":"warn","msg":"a test","src":"std"}` + "\n"
+	expected := `{"level":"info","msg":"another test","src":"test src"}
+{"level":"info","msg":"[E] not classified","src":"test src"}
+{"level":"err","msg":"classified","src":"test src"}
+{"level":"warn","msg":"also classified","src":"test src"}
+{"level":"warn","msg":"http: TLS handshake error from 10.0.0.1:1234: EOF","src":"test src"}
+{"level":"err","msg":"something failed","src":"test src"}
+{"level":"info","msg":"just info","src":"test src"}
+{"level":"info","msg":"I/O timeout","src":"test src"}
+{"level":"info","msg":"E-mail sent","src":"test src"}
+{"level":"info","msg":"T-minus 10","src":"test src"}
+{"level":"warn","msg":"disk almost full","src":"test src"}
+`
 	if actual != expected {
 		t.Errorf("unexpected:\n%s\nvs:\n%s\n", actual, expected)
 	}
 }
 
-func TestNewStdLogger(t *testing.T) {
-	SetLogLevel(Info)
-	Config.LogFileAndLine = true
-	Config.JSON = false
+func TestDefaultLevelClassifierTraceAndVerbose(t *testing.T) {
+	for msg, expected := range map[string]Level{
+		"trace: x": Trace, "TRC x": Trace, "[T] x": Trace,
+		"verbose: x": Verbose, "[vrb] x": Verbose, "[V] x": Verbose,
+	} {
+		if lvl, stripped, ok := DefaultLevelClassifier(msg); !ok || lvl != expected || stripped != "x" {
+			t.Errorf("unexpected classification of %q: %v %q %v", msg, lvl, stripped, ok)
+		}
+	}
+}
+
+func TestLogRequestRedaction(t *testing.T) {
+	SetLogLevel(Verbose)
+	Config.LogFileAndLine = false
+	Config.JSON = true
 	Config.NoTimestamp = true
+	Config.GoroutineID = false
 	var b bytes.Buffer
-	w := bufio.NewWriter(&b)
-	SetOutput(w)
-	logger := NewStdLogger("test src", Info)
-	logger.Printf("\n\nanother test\n\n")
-	w.Flush()
+	SetOutput(&b)
+	SetRedactor(DefaultRedactor())
+	defer SetRedactor(nil)
+	h := http.Header{
+		"Authorization": []string{"Bearer xyz"}, "Cookie": []string{"session=abc"},
+		"X-Api-Key": []string{"k1"}, "Accept": []string{"*/*"},
+	}
+	r := &http.Request{Header: h, URL: &url.URL{Path: "/x", RawQuery: "email=john@example.com"}}
+	LogRequest(r, "redacted")
 	actual := b.String()
-	expected := `{"level":"info","msg":"another test","src":"test src"}` + "\n"
+	//nolint: lll // long lines in expected.
+	expected := `{"level":"info","msg":"redacted","method":"","url":"/x?email=[REDACTED]","host":"","proto":"","remote_addr":"","header.accept":"*/*","header.x-api-key":"[REDACTED]"}
+`
 	if actual != expected {
 		t.Errorf("unexpected:\n%s\nvs:\n%s\n", actual, expected)
 	}
+	// Credentials headers are denied by default, even without redaction.
+	b.Reset()
+	SetRedactor(nil)
+	LogRequest(r, "not redacted")
+	actual = b.String()
+	//nolint: lll // long lines in expected.
+	expected = `{"level":"info","msg":"not redacted","method":"","url":"/x?email=john@example.com","host":"","proto":"","remote_addr":"","header.accept":"*/*","header.x-api-key":"k1"}
+`
+	if actual != expected {
+		t.Errorf("unexpected:\n%s\nvs:\n%s\n", actual, expected)
+	}
+	Config.GoroutineID = true
+}
+
+func TestLogRequestHeaderRules(t *testing.T) {
+	SetLogLevelQuiet(Info)
+	Config.LogFileAndLine = false
+	Config.JSON = true
+	Config.NoTimestamp = true
+	Config.GoroutineID = false
+	Config.CombineRequestAndResponse = true
+	var b bytes.Buffer
+	SetOutput(&b)
+	opts := &HTTPLogOptions{
+		RequestHeaders: HeaderRules{
+			Headers: []HeaderLevel{{"X-Debug", Debug}, {"Accept", Info}, {"Authorization", Info}, {"User-Agent", Info}},
+			All:     Debug,
+			Deny:    []string{"authorization"},
+		},
+		ResponseHeaders: HeaderRules{
+			Headers: []HeaderLevel{{"Content-Type", Info}},
+			All:     NoLevel,
+		},
+		RedactQueryParams: []string{"token", "SIG"},
+	}
+	h := http.Header{
+		"Authorization": []string{"Bearer xyz"}, "Accept": []string{"a", "b"},
+		"X-Debug": []string{"1"}, "User-Agent": []string{"ua"},
+	}
+	r := &http.Request{Header: h, URL: &url.URL{Path: "/x", RawQuery: "a=1&token=abc&sig=x%20y&b&to%6Ben=2"}}
+	handler := func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("X-Other", "foo")
+		w.Write([]byte("hi"))
+	}
+	LogAndCallWithOptions(opts, "combined", handler).ServeHTTP(&NullHTTPWriter{}, r)
+	SetLogLevelQuiet(Debug)
+	LogRequestWithOptions(opts, r, "debug")
+	opts.ResponseHeaders.All = Debug
+	Config.CombineRequestAndResponse = false
+	LogAndCallWithOptions(opts, "split", handler).ServeHTTP(&NullHTTPWriter{}, r)
+	actual := regexp.MustCompile(`"(ttfb_)?microsec":\d+`).ReplaceAllString(b.String(), `"${1}microsec":0`)
+	redactedURL := `"url":"/x?a=1&token=[REDACTED]&sig=[REDACTED]&b&to%6Ben=[REDACTED]"`
+	//nolint: lll // long lines in expected.
+	expected := `{"level":"info","msg":"combined","method":"",` + redactedURL + `,"host":"","proto":"","remote_addr":"","header.accept":"a","user-agent":"ua","status":200,"size":2,"microsec":0,"ttfb_microsec":0,"resp.header.content-type":"text/plain"}
+{"level":"info","msg":"debug","method":"",` + redactedURL + `,"host":"","proto":"","remote_addr":"","header.accept":"a,b","user-agent":"ua","header.x-debug":"1"}
+{"level":"info","msg":"split","method":"",` + redactedURL + `,"host":"","proto":"","remote_addr":"","header.accept":"a,b","user-agent":"ua","header.x-debug":"1"}
+{"level":"info","msg":"split","status":200,"size":2,"ttfb_microsec":0,"resp.header.content-type":"text/plain","resp.header.x-other":"foo","microsec":0}
+`
+	if actual != expected {
+		t.Errorf("unexpected:\n%s\nvs:\n%s\n", actual, expected)
+	}
+	SetLogLevelQuiet(Info)
+	Config.GoroutineID = true
+}
+
+type fullWriter struct {
+	NullHTTPWriter
+	pushed string
+}
+
+func (f *fullWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, errors.New("fake hijack error")
+}
+
+func (f *fullWriter) Push(target string, _ *http.PushOptions) error {
+	f.pushed = target
+	return nil
+}
+
+func (f *fullWriter) ReadFrom(src io.Reader) (int64, error) {
+	return io.Copy(io.Discard, src)
+}
+
+func TestResponseRecorderInterfaces(t *testing.T) {
+	rr := &ResponseRecorder{w: &NullHTTPWriter{}}
+	w := rr.ResponseWriter()
+	if w != http.ResponseWriter(rr) {
+		t.Errorf("expected the recorder itself when no optional interface is available")
+	}
+	if _, ok := w.(http.Hijacker); ok {
+		t.Errorf("unexpected http.Hijacker")
+	}
+	if _, ok := w.(io.ReaderFrom); ok {
+		t.Errorf("unexpected io.ReaderFrom")
+	}
+	if u, ok := w.(interface{ Unwrap() http.ResponseWriter }); !ok || u.Unwrap() != rr.w {
+		t.Errorf("expected Unwrap() to return the wrapped writer")
+	}
+	fw := &fullWriter{}
+	rr = &ResponseRecorder{w: fw}
+	w = rr.ResponseWriter()
+	if _, _, err := w.(http.Hijacker).Hijack(); err == nil || rr.Hijacked {
+		t.Errorf("expected hijack error to be passed through and not marked as hijacked")
+	}
+	if err := w.(http.Pusher).Push("/foo", nil); err != nil || fw.pushed != "/foo" {
+		t.Errorf("unexpected push result %v %q", err, fw.pushed)
+	}
+	n, err := io.Copy(w, strings.NewReader("12345"))
+	if err != nil || n != 5 || rr.ContentLength != 5 || rr.StatusCode != http.StatusOK {
+		t.Errorf("unexpected ReadFrom result %d %v %d %d", n, err, rr.ContentLength, rr.StatusCode)
+	}
+	if _, ok := w.(http.Flusher); !ok {
+		t.Errorf("expected http.Flusher")
+	}
+}
+
+func TestLogAndCallHijackAndReadFrom(t *testing.T) {
+	SetLogLevelQuiet(Info)
+	Config.LogFileAndLine = false
+	Config.JSON = true
+	Config.NoTimestamp = true
+	Config.GoroutineID = false
+	Config.CombineRequestAndResponse = true
+	var b bytes.Buffer
+	var mu sync.Mutex
+	SetOutput(writerFunc(func(p []byte) (int, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return b.Write(p)
+	}))
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hijack", LogAndCall("hijack", func(w http.ResponseWriter, _ *http.Request) {
+		conn, brw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("unexpected hijack error %v", err)
+			return
+		}
+		defer conn.Close()
+		brw.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 2\r\nConnection: close\r\n\r\nhi")
+		brw.Flush()
+	}))
+	mux.HandleFunc("/copy", LogAndCall("copy", func(w http.ResponseWriter, _ *http.Request) {
+		if _, ok := w.(io.ReaderFrom); !ok {
+			t.Errorf("expected io.ReaderFrom to be exposed")
+		}
+		io.Copy(w, strings.NewReader("some data"))
+	}))
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	for _, path := range []string{"/hijack", "/copy"} {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatalf("unexpected error for %s: %v", path, err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+	mu.Lock()
+	actual := b.String()
+	mu.Unlock()
+	if !strings.Contains(actual, `"status":0,"size":0,"microsec":`) ||
+		!strings.Contains(actual, `"hijacked":true,"hijacked_bytes":59}`) {
+		t.Errorf("missing hijacked info in %s", actual)
+	}
+	if !strings.Contains(actual, `"status":200,"size":9,`) {
+		t.Errorf("missing ReadFrom size in %s", actual)
+	}
+	Config.GoroutineID = true
+}
+
+func TestLogAndCallTTFBBodyAndClientGone(t *testing.T) {
+	SetLogLevelQuiet(Info)
+	Config.LogFileAndLine = false
+	Config.JSON = true
+	Config.NoTimestamp = true
+	Config.GoroutineID = false
+	Config.CombineRequestAndResponse = true
+	var b bytes.Buffer
+	var mu sync.Mutex
+	SetOutput(writerFunc(func(p []byte) (int, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return b.Write(p)
+	}))
+	logged := make(chan struct{}, 1)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/read", LogAndCall("read", func(w http.ResponseWriter, r *http.Request) {
+		buf := make([]byte, 3)
+		io.ReadFull(r.Body, buf)
+		time.Sleep(20 * time.Millisecond)
+		w.Write(buf)
+	}))
+	mux.HandleFunc("/gone", func(w http.ResponseWriter, r *http.Request) {
+		LogAndCall("gone", func(_ http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+		}).ServeHTTP(w, r)
+		logged <- struct{}{}
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	resp, err := http.Post(srv.URL+"/read", "text/plain", strings.NewReader("abcdef"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/gone", nil)
+	if _, err = http.DefaultClient.Do(req); err == nil {
+		t.Errorf("expected timeout error")
+	}
+	<-logged
+	mu.Lock()
+	actual := b.String()
+	mu.Unlock()
+	m := regexp.MustCompile(`"status":200,"size":3,"microsec":(\d+),"ttfb_microsec":(\d+),"bytes_read":3}`).FindStringSubmatch(actual)
+	if m == nil {
+		t.Fatalf("missing ttfb/bytes_read in %s", actual)
+	}
+	if total, _ := strconv.Atoi(m[1]); total < 20000 {
+		t.Errorf("unexpected total duration %s", m[1])
+	}
+	if ttfb, _ := strconv.Atoi(m[2]); ttfb < 20000 {
+		t.Errorf("unexpected ttfb %s", m[2])
+	}
+	if !regexp.MustCompile(`"msg":"gone",.*"status":0,"size":0,"microsec":\d+,"client_gone":true}`).MatchString(actual) {
+		t.Errorf("missing client_gone in %s", actual)
+	}
+	Config.GoroutineID = true
+}
+
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
+
+func TestLogAndCallCorrelation(t *testing.T) {
+	SetLogLevelQuiet(Info)
+	Config.LogFileAndLine = false
+	Config.JSON = true
+	Config.NoTimestamp = true
+	Config.GoroutineID = false
+	Config.CombineRequestAndResponse = true
+	var b bytes.Buffer
+	SetOutput(&b)
+	opts := &HTTPLogOptions{TraceContext: true, RequestIDHeader: "X-Request-Id"}
+	handler := LogAndCallWithOptions(opts, "corr", func(_ http.ResponseWriter, r *http.Request) {
+		SCtx(r.Context(), Info, "inside", Str("k", "v"))
+	}, Str("extra", "x"))
+	r := &http.Request{Header: http.Header{
+		"Traceparent":  []string{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+		"Tracestate":   []string{"a=1"},
+		"X-Request-Id": []string{"req-123"},
+	}}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if id := w.Header().Get("X-Request-Id"); id != "req-123" {
+		t.Errorf("expected request id to be echoed, got %q", id)
+	}
+	Config.CombineRequestAndResponse = false
+	r.Header = http.Header{"Traceparent": []string{"00-00000000000000000000000000000000-00f067aa0ba902b7-01"}}
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	genID := w.Header().Get("X-Request-Id")
+	if len(genID) != 32 {
+		t.Errorf("expected generated request id, got %q", genID)
+	}
+	actual := regexp.MustCompile(`"(\w*)microsec":\d+`).ReplaceAllString(b.String(), `"${1}microsec":0`)
+	ids := `"trace_id":"4bf92f3577b34da6a3ce929d0e0e4736","span_id":"00f067aa0ba902b7","tracestate":"a=1","req_id":"req-123"`
+	//nolint: lll // long lines in expected.
+	expected := `{"level":"info","msg":"inside",` + ids + `,"k":"v"}
+{"level":"info","msg":"corr","method":"","url":null,"host":"","proto":"","remote_addr":"","status":0,"size":0,"microsec":0,` + ids + `,"extra":"x"}
+{"level":"info","msg":"corr","method":"","url":null,"host":"","proto":"","remote_addr":"","req_id":"` + genID + `","extra":"x"}
+{"level":"info","msg":"inside","req_id":"` + genID + `","k":"v"}
+{"level":"info","msg":"corr","status":0,"size":0,"req_id":"` + genID + `","microsec":0}
+`
+	if actual != expected {
+		t.Errorf("unexpected:\n%s\nvs:\n%s\n", actual, expected)
+	}
+	// TraceContext is on by default, RequestIDHeader isn't.
+	b.Reset()
+	Config.CombineRequestAndResponse = true
+	r.Header = http.Header{"Traceparent": []string{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}}
+	w = httptest.NewRecorder()
+	LogAndCallWithOptions(DefaultHTTPLogOptions(), "default", func(http.ResponseWriter, *http.Request) {}).ServeHTTP(w, r)
+	actual = b.String()
+	if !strings.Contains(actual, `"trace_id":"4bf92f3577b34da6a3ce929d0e0e4736","span_id":"00f067aa0ba902b7"`) ||
+		strings.Contains(actual, "req_id") || w.Header().Get("X-Request-Id") != "" {
+		t.Errorf("unexpected default correlation: %s", actual)
+	}
+	Config.GoroutineID = true
+}
+
+func TestParseTraceParent(t *testing.T) {
+	for _, tst := range []struct {
+		in string
+		ok bool
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-future", true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01", false},
+		{"", false},
+	} {
+		traceID, spanID, ok := ParseTraceParent(tst.in)
+		if ok != tst.ok || (ok && (traceID != "4bf92f3577b34da6a3ce929d0e0e4736" || spanID != "00f067aa0ba902b7")) {
+			t.Errorf("unexpected for %q: %q %q %v", tst.in, traceID, spanID, ok)
+		}
+	}
+}
+
+func TestLogAndCallRequestBuffering(t *testing.T) {
+	SetLogLevelQuiet(Info)
+	Config.LogFileAndLine = false
+	Config.JSON = true
+	Config.NoTimestamp = true
+	Config.GoroutineID = false
+	Config.CombineRequestAndResponse = true
+	var b bytes.Buffer
+	SetOutput(&b)
+	opts := &HTTPLogOptions{RequestLogBufferSize: 150, LogLevelHeader: "X-Log-Level",
+		LogLevelAuthorize: func(r *http.Request) bool { return r.URL.Path != "/untrusted" }}
+	handler := LogAndCallWithOptions(opts, "req", func(w http.ResponseWriter, r *http.Request) {
+		SCtx(r.Context(), Debug, "debug "+r.URL.Path)
+		SCtx(r.Context(), Verbose, "verbose "+r.URL.Path)
+		SCtx(r.Context(), Info, "info "+r.URL.Path)
+		switch r.URL.Path {
+		case "/fail":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "/panic":
+			panic("boom")
+		case "/big":
+			for i := 0; i < 3; i++ {
+				SCtx(r.Context(), Debug, "filling the buffer")
+			}
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+	for _, path := range []string{"/ok", "/fail", "/panic", "/big", "/hdr", "/untrusted"} {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		if path == "/hdr" || path == "/untrusted" {
+			r.Header.Set("X-Log-Level", "VERBOSE")
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+	actual := regexp.MustCompile(`,"method".*`).ReplaceAllString(b.String(), "")
+	expected := `{"level":"info","msg":"info /ok"}
+{"level":"info","msg":"req"
+{"level":"info","msg":"info /fail"}
+{"level":"dbug","msg":"debug /fail"}
+{"level":"verbose","msg":"verbose /fail"}
+{"level":"info","msg":"req"
+{"level":"info","msg":"info /panic"}
+{"level":"crit","msg":"panic in handler","error":"boom"}
+{"level":"dbug","msg":"debug /panic"}
+{"level":"verbose","msg":"verbose /panic"}
+{"level":"info","msg":"req"
+{"level":"info","msg":"info /big"}
+{"level":"dbug","msg":"debug /big"}
+{"level":"verbose","msg":"verbose /big"}
+{"level":"dbug","msg":"filling the buffer"}
+{"level":"warn","msg":"request log buffer full","dropped":2}
+{"level":"info","msg":"req"
+{"level":"verbose","msg":"verbose /hdr"}
+{"level":"info","msg":"info /hdr"}
+{"level":"info","msg":"req"
+{"level":"info","msg":"info /untrusted"}
+{"level":"info","msg":"req"
+`
+	if actual != expected {
+		t.Errorf("unexpected:\n%s\nvs:\n%s\n", actual, expected)
+	}
+	// split mode, buffered entries also get flushed on panic.
+	Config.CombineRequestAndResponse = false
+	b.Reset()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/panic", nil))
+	if !strings.Contains(b.String(), `{"level":"dbug","msg":"debug /panic"}`) {
+		t.Errorf("expected buffered entries to be flushed on panic, got %s", b.String())
+	}
+	Config.CombineRequestAndResponse = true
+	Config.GoroutineID = true
+}
+
+func TestLogAndCallPanics(t *testing.T) {
+	SetLogLevelQuiet(Verbose)
+	Config.LogFileAndLine = false
+	Config.JSON = true
+	Config.NoTimestamp = true
+	Config.GoroutineID = false
+	Config.CombineRequestAndResponse = false
+	var b bytes.Buffer
+	SetOutput(&b)
+	opts := &HTTPLogOptions{PanicStackDepth: 2, PanicSkipRuntimeFrames: true}
+	handler := LogAndCallWithOptions(opts, "split", testHandler)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/panicbefore", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected 500 error, got %d", w.Code)
+	}
+	actual := regexp.MustCompile(`"microsec":\d+`).ReplaceAllString(b.String(), `"microsec":0`)
+	actual = regexp.MustCompile(`:\d+\\n`).ReplaceAllString(actual, `:0\n`)
+	//nolint: lll // long lines in expected.
+	expected := `{"level":"info","msg":"split","method":"GET","url":"/panicbefore","host":"example.com","proto":"HTTP/1.1","remote_addr":"192.0.2.1:1234"}
+{"level":"crit","msg":"panic in handler","error":"some test handler panic before response"}
+{"level":"verbose","msg":"stack trace","stack":"fortio.org/log.testHandler\n\t` + srcFile("http_logging_test.go") + `:0\nfortio.org/log.LogAndCallWithOptions.func1\n\t` + srcFile("http_logging.go") + `:0\n...\n"}
+{"level":"info","msg":"split","status":-500,"size":0,"microsec":0}
+`
+	if actual != expected {
+		t.Errorf("unexpected:\n%s\nvs:\n%s\n", actual, expected)
+	}
+	// Abort handler re-panic (by default).
+	Config.CombineRequestAndResponse = true
+	b.Reset()
+	func() {
+		defer func() {
+			if err := recover(); err != http.ErrAbortHandler { //nolint:errorlint // sentinel.
+				t.Errorf("expected ErrAbortHandler re-panic, got %v", err)
+			}
+		}()
+		LogAndCallWithOptions(opts, "abort", func(http.ResponseWriter, *http.Request) {
+			panic(http.ErrAbortHandler)
+		}).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/abort", nil))
+	}()
+	if strings.Contains(b.String(), "panic in handler") || !strings.Contains(b.String(), `"msg":"abort"`) ||
+		!strings.Contains(b.String(), `"status":-500`) {
+		t.Errorf("unexpected abort log: %s", b.String())
+	}
+	// Swallowed when asked.
+	opts.SwallowAbortHandler = true
+	b.Reset()
+	w = httptest.NewRecorder()
+	LogAndCallWithOptions(opts, "abort", func(http.ResponseWriter, *http.Request) {
+		panic(http.ErrAbortHandler)
+	}).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/abort", nil))
+	if w.Code != http.StatusInternalServerError || !strings.Contains(b.String(), "panic in handler") {
+		t.Errorf("expected swallowed abort panic, got %d: %s", w.Code, b.String())
+	}
+	SetLogLevelQuiet(Info)
+	Config.GoroutineID = true
+}
+
+// srcFile returns the absolute path of a file of this package, as it appears in stack traces.
+func srcFile(name string) string {
+	_, file, _, _ := runtime.Caller(0)
+	return file[:strings.LastIndex(file, "/")+1] + name
 }
@@ -0,0 +1,102 @@
+// Copyright 2026 Fortio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !no_http && !no_net
+
+// Live tail of the RingBuffer sink over http (JSON lines or Server-Sent Events).
+
+package log // import "fortio.org/log"
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// sseKeepAlive is how often a comment is sent on idle SSE streams (so proxies don't time them out).
+const sseKeepAlive = 15 * time.Second
+
+// parseEntryFilter returns the filter from the level and attr (repeated key:value) query parameters.
+func parseEntryFilter(r *http.Request) (*EntryFilter, error) {
+	f := &EntryFilter{}
+	q := r.URL.Query()
+	if lvlStr := q.Get("level"); lvlStr != "" {
+		lvl, err := ValidateLevel(lvlStr)
+		if err != nil {
+			return nil, err
+		}
+		f.MinLevel = &lvl
+	}
+	for _, attr := range q["attr"] {
+		key, value, _ := strings.Cut(attr, ":")
+		if f.Attrs == nil {
+			f.Attrs = make(map[string]string)
+		}
+		f.Attrs[key] = value
+	}
+	return f, nil
+}
+
+// TailHandler returns a http.Handler returning the entries recorded by the ring buffer as JSON lines or,
+// when requested with an "Accept: text/event-stream" header (e.g. by a browser EventSource), as Server-Sent
+// Events followed by the new entries as they are logged. Entries can be filtered with the level (minimum level)
+// and attr (key:value, can be repeated) query parameters, e.g. /debug/tail?level=warning&attr=req_id:abc.
+// It doesn't do any access control, wrap it as needed.
+func (rb *RingBuffer) TailHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		filter, err := parseEntryFilter(r)
+		if err != nil {
+			http.Error(w, "Invalid level: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		flusher, canFlush := w.(http.Flusher)
+		if !canFlush || !strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			for _, e := range rb.Entries() {
+				if filter.Match(e) {
+					_, _ = w.Write([]byte(e.JSON + "\n"))
+				}
+			}
+			return
+		}
+		entries, ch := rb.subscribe(256)
+		defer rb.unsubscribe(ch)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		for _, e := range entries {
+			if filter.Match(e) {
+				_, _ = w.Write([]byte("data: " + e.JSON + "\n\n"))
+			}
+		}
+		flusher.Flush()
+		ticker := time.NewTicker(sseKeepAlive)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-ticker.C:
+				_, _ = w.Write([]byte(": keepalive\n\n"))
+			case e := <-ch:
+				if !filter.Match(e) {
+					continue
+				}
+				if _, err := w.Write([]byte("data: " + e.JSON + "\n\n")); err != nil {
+					return
+				}
+			}
+			flusher.Flush()
+		}
+	})
+}
@@ -0,0 +1,99 @@
+// Copyright 2026 Fortio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !no_http && !no_net
+
+package log // import "fortio.org/log"
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTailHandler(t *testing.T) {
+	SetLogLevelQuiet(Info)
+	Config.LogFileAndLine = false
+	Config.JSON = true
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	rb := NewRingBuffer(10)
+	AddSink(rb)
+	defer RemoveSink(rb)
+	S(Info, "one", Str("req_id", "a"))
+	S(Warning, "two", Str("req_id", "b"))
+	S(Error, "three", Str("req_id", "a"))
+	SetLogLevelQuiet(Trace)
+	S(Trace, "four")
+	SetLogLevelQuiet(Info)
+	srv := httptest.NewServer(rb.TailHandler())
+	defer srv.Close()
+	get := func(query string) string {
+		resp, err := http.Get(srv.URL + query)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return string(data)
+	}
+	all := get("/")
+	if strings.Count(all, "\n") != 4 || !strings.Contains(all, `"msg":"one"`) {
+		t.Errorf("unexpected all entries: %s", all)
+	}
+	filtered := get("/?level=warning&attr=req_id:a")
+	if strings.Count(filtered, "\n") != 1 || !strings.Contains(filtered, `"msg":"three","req_id":"a"}`) {
+		t.Errorf("unexpected filtered entries: %s", filtered)
+	}
+	if debug := get("/?level=debug"); strings.Count(debug, "\n") != 3 || strings.Contains(debug, `"msg":"four"`) {
+		t.Errorf("unexpected debug level entries: %s", debug)
+	}
+	if bad := get("/?level=foo"); !strings.HasPrefix(bad, "Invalid level") {
+		t.Errorf("unexpected bad level response: %s", bad)
+	}
+	// SSE
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/?attr=req_id:a", nil)
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("unexpected content type %q", ct)
+	}
+	reader := bufio.NewReader(resp.Body)
+	readEvent := func() string {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("unexpected read error: %v", err)
+		}
+		reader.ReadString('\n') // empty line separator
+		return line
+	}
+	for _, msg := range []string{`"msg":"one"`, `"msg":"three"`} {
+		if ev := readEvent(); !strings.HasPrefix(ev, "data: {") || !strings.Contains(ev, msg) {
+			t.Errorf("unexpected event %q, expected %s", ev, msg)
+		}
+	}
+	S(Info, "skipped", Str("req_id", "b"))
+	S(Info, "live", Str("req_id", "a"))
+	if ev := readEvent(); !strings.Contains(ev, `"msg":"live"`) {
+		t.Errorf("unexpected live event %q", ev)
+	}
+}
@@ -22,7 +22,6 @@ package log // import "fortio.org/log"
 import (
 	"encoding/json"
 	"fmt"
-	"strconv"
 )
 
 var fullJSON = true
@@ -30,7 +29,7 @@ var fullJSON = true
 func toJSON(v any) string {
 	bytes, err := json.Marshal(v)
 	if err != nil {
-		return strconv.Quote(fmt.Sprintf("ERR marshaling %v: %v", v, err))
+		return jsonString(fmt.Sprintf("ERR marshaling %v: %v", v, err))
 	}
 	str := string(bytes)
 	// We now handle errors before calling toJSON: if there is a marshaller we use it
@@ -39,13 +38,18 @@ func toJSON(v any) string {
 }
 
 func (v ValueType[T]) String() string {
-	// if the type is numeric, use Sprint(v.val) otherwise use Sprintf("%q", v.Val) to quote it.
+	// if the type is numeric, use Sprint(v.val) otherwise quote it (see jsonString()).
 	switch s := any(v.Val).(type) {
-	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64,
-		float32, float64:
+	case LogValuer:
+		return ValueType[any]{Val: resolveLogValuer(s)}.String()
+	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
 		return fmt.Sprint(s)
+	case float32:
+		return jsonFloat(s)
+	case float64:
+		return jsonFloat(s)
 	case string:
-		return fmt.Sprintf("%q", s)
+		return jsonString(s)
 	case error:
 		// Sadly structured errors like nettwork error don't have the reason in
 		// the exposed struct/JSON - ie one gets
@@ -58,7 +62,7 @@ func (v ValueType[T]) String() string {
 		if hasMarshaller {
 			return toJSON(v.Val)
 		}
-		return fmt.Sprintf("%q", s.Error())
+		return jsonString(s.Error())
 	/* It's all handled by json fallback now even though slightly more expensive at runtime, it's a lot simpler */
 	default:
 		return toJSON(v.Val) // was fmt.Sprintf("%q", fmt.Sprint(v.Val))
@@ -0,0 +1,105 @@
+// Copyright 2026 Fortio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !no_http && !no_net
+
+// Inference of the level of messages from other loggers (e.g. the standard library's).
+
+package log // import "fortio.org/log"
+
+import (
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// LevelClassifier infers the level of a message (e.g. from its prefix), returning the message to log
+// (e.g. without that prefix) and ok false if the level can't be inferred (and the default should be used).
+type LevelClassifier func(msg string) (lvl Level, stripped string, ok bool)
+
+// LevelRule is a regular expression which, when matching a message, gives its level.
+type LevelRule struct {
+	Pattern *regexp.Regexp
+	Level   Level
+	// If true, the matched part is removed from the logged message (e.g. for a level prefix).
+	Strip bool
+}
+
+// RulesClassifier returns a LevelClassifier using the first matching rule.
+func RulesClassifier(rules ...LevelRule) LevelClassifier {
+	return func(msg string) (Level, string, bool) {
+		for _, rule := range rules {
+			loc := rule.Pattern.FindStringIndex(msg)
+			if loc == nil {
+				continue
+			}
+			if rule.Strip {
+				msg = msg[:loc[0]] + msg[loc[1]:]
+			}
+			return rule.Level, msg, true
+		}
+		return 0, msg, false
+	}
+}
+
+// levelPrefix returns a rule for messages starting with the single letter tag in brackets (e.g. "[W] ")
+// or with one of the words, either in brackets or followed by a colon or a space, e.g. "WARN: " or "warning ".
+// Single letters aren't matched outside of brackets so "I/O timeout" or "E-mail sent" aren't classified.
+func levelPrefix(lvl Level, tag, words string) LevelRule {
+	return LevelRule{
+		Pattern: regexp.MustCompile(`^(?i)\s*(?:\[(?:` + tag + `|` + words + `)\]|(?:` + words + `)(?::|\s))\s*`),
+		Level:   lvl,
+		Strip:   true,
+	}
+}
+
+// DefaultLevelRules returns the rules of DefaultLevelClassifier: level prefixes (ours like "[W]" and the
+// usual words like "error:", "WARN", "[info]") and messages of the net/http server's ErrorLog.
+func DefaultLevelRules() []LevelRule {
+	return []LevelRule{
+		levelPrefix(Trace, "T", "trace|trc"),
+		levelPrefix(Debug, "D", "debug|dbg"),
+		levelPrefix(Verbose, "V", "verbose|vrb"),
+		levelPrefix(Info, "I", "info"),
+		levelPrefix(Warning, "W", "warn|warning"),
+		levelPrefix(Error, "E", "err|error"),
+		levelPrefix(Critical, "C", "crit|critical|panic"),
+		levelPrefix(Fatal, "F", "fatal"),
+		{Pattern: regexp.MustCompile(`^http2?: panic serving`), Level: Critical},
+		{Pattern: regexp.MustCompile(`^http2?: (?:TLS handshake error|URL query contains semicolon)`), Level: Warning},
+		{Pattern: regexp.MustCompile(`(?i)\b(?:error|failed|failure)\b`), Level: Error},
+		{Pattern: regexp.MustCompile(`(?i)\bwarn(?:ing)?\b`), Level: Warning},
+	}
+}
+
+// DefaultLevelClassifier is the classifier for the DefaultLevelRules.
+var DefaultLevelClassifier = RulesClassifier(DefaultLevelRules()...)
+
+// callerOutsideLog returns the file (base name) and line of the first caller outside of this
+// package and of the standard library's log package (i.e. the code that called the std logger).
+func callerOutsideLog() (string, int) {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		inLog := strings.HasPrefix(frame.Function, "log.") || strings.HasPrefix(frame.Function, "fortio.org/log.")
+		if !inLog {
+			return frame.File[strings.LastIndex(frame.File, "/")+1:], frame.Line
+		}
+		if !more {
+			return "", 0
+		}
+	}
+}
@@ -0,0 +1,225 @@
+// Copyright 2026 Fortio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Trace and custom levels, and the level accessors (for the built-in levels they use the exported
+// LevelToStrA, LevelToJSON, LevelToColor and LevelToText slices).
+
+package log // import "fortio.org/log"
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Trace is the finest level, below Debug (-1 being used for invalid levels).
+const Trace Level = -2
+
+// LevelInfo describes a level beyond the built-in Debug to Fatal ones, see RegisterLevel.
+type LevelInfo struct {
+	Name  string // e.g. "Notice", for String(), ValidateLevel and the flags (the lowercase version works too).
+	JSON  string // level in JSON entries (unquoted), e.g. "notice".
+	Color string // color mode color, e.g. log.ANSIColors.Blue.
+	Tag   string // single character tag for text mode, e.g. "N" for "[N]".
+	json  string // quoted version of JSON.
+	text  string // 3 letters version for color mode (LevelToText).
+}
+
+var (
+	levelsMutex  sync.Mutex
+	extraLevels  atomic.Value // map[Level]*LevelInfo, copy on write.
+	levelLookups atomic.Value // *levelLookup, copy on write.
+)
+
+// levelLookup maps the level names (as is and lowercase) and the JSON strings of all the levels
+// (built-in, Trace and custom ones) to the levels.
+type levelLookup struct {
+	byName map[string]Level
+	byJSON map[string]Level
+}
+
+func getLevelLookup() *levelLookup {
+	l, _ := levelLookups.Load().(*levelLookup)
+	return l
+}
+
+// copyLevelMap returns a copy of m with room for extra more entries.
+func copyLevelMap(m map[string]Level, extra int) map[string]Level {
+	res := make(map[string]Level, len(m)+extra)
+	for k, v := range m {
+		res[k] = v
+	}
+	return res
+}
+
+// RegisterLevel adds a custom level (e.g. Notice, Audit). The value must be below Debug (and not -1) or
+// above NoLevel (levels are ordered by value: custom levels above NoLevel are always logged) and the name
+// and JSON strings must not be already used. Custom levels can then be used with Logf(), S() etc.
+// It should be called before the flags are parsed and the logging starts, typically from an init(), as it
+// also adds the level to the exported JSONStringLevelToLevel map (which isn't safe to read concurrently).
+func RegisterLevel(lvl Level, info LevelInfo) error {
+	if (lvl >= -1 && lvl <= NoLevel) || info.Name == "" || info.JSON == "" || len(info.Tag) != 1 {
+		return fmt.Errorf("invalid level %d %+v: value must be < -1 or > %d and name, json and 1 char tag are required",
+			lvl, info, NoLevel)
+	}
+	levelsMutex.Lock()
+	defer levelsMutex.Unlock()
+	current := getExtraLevels()
+	if _, found := current[lvl]; found {
+		return fmt.Errorf("level %d already registered", lvl)
+	}
+	lookup := getLevelLookup()
+	lowerName := strings.ToLower(info.Name)
+	_, found := lookup.byName[info.Name]
+	_, lowerFound := lookup.byName[lowerName]
+	if found || lowerFound {
+		return fmt.Errorf("level name %q already used", info.Name)
+	}
+	if _, found := lookup.byJSON[info.JSON]; found {
+		return fmt.Errorf("level json %q already used", info.JSON)
+	}
+	info.json = jsonString(info.JSON)
+	info.text = strings.ToUpper(info.Name)
+	if len(info.text) > 3 {
+		info.text = info.text[:3]
+	}
+	updated := make(map[Level]*LevelInfo, len(current)+1)
+	for l, i := range current {
+		updated[l] = i
+	}
+	updated[lvl] = &info
+	extraLevels.Store(updated)
+	newLookup := &levelLookup{byName: copyLevelMap(lookup.byName, 2), byJSON: copyLevelMap(lookup.byJSON, 1)}
+	newLookup.byName[info.Name] = lvl
+	newLookup.byName[lowerName] = lvl
+	newLookup.byJSON[info.JSON] = lvl
+	levelLookups.Store(newLookup)
+	JSONStringLevelToLevel[info.JSON] = lvl
+	return nil
+}
+
+func getExtraLevels() map[Level]*LevelInfo {
+	m, _ := extraLevels.Load().(map[Level]*LevelInfo)
+	return m
+}
+
+func registerBuiltinLevels() {
+	err := RegisterLevel(Trace, LevelInfo{Name: "Trace", JSON: "trace", Color: ANSIColors.DarkGray, Tag: "T"})
+	if err != nil {
+		panic(err)
+	}
+}
+
+func isBuiltin(lvl Level) bool {
+	return lvl >= Debug && lvl <= NoLevel
+}
+
+// isKnown returns true for the built-in and registered levels.
+func isKnown(lvl Level) bool {
+	if isBuiltin(lvl) {
+		return true
+	}
+	_, found := getExtraLevels()[lvl]
+	return found
+}
+
+// LevelNames returns the names of the levels which can be set (see SetLogLevel), in order.
+func LevelNames() []string {
+	levels := make([]Level, 0, len(LevelToStrA)+len(getExtraLevels()))
+	for l := range getExtraLevels() {
+		if l < Debug {
+			levels = append(levels, l)
+		}
+	}
+	sort.Slice(levels, func(i, j int) bool { return levels[i] < levels[j] })
+	for l := Debug; l <= Critical; l++ {
+		levels = append(levels, l)
+	}
+	names := make([]string, len(levels))
+	for i, l := range levels {
+		names[i] = l.String()
+	}
+	return names
+}
+
+// allLevelNames returns the names of all the levels (LevelNames plus Fatal and the custom ones above it).
+func allLevelNames() []string {
+	res := LevelNames()
+	res = append(res, Fatal.String())
+	var above []Level
+	for l := range getExtraLevels() {
+		if l > NoLevel {
+			above = append(above, l)
+		}
+	}
+	sort.Slice(above, func(i, j int) bool { return above[i] < above[j] })
+	for _, l := range above {
+		res = append(res, l.String())
+	}
+	return res
+}
+
+// extraInfo returns the registered info for lvl, or a placeholder for unknown levels.
+func extraInfo(lvl Level) *LevelInfo {
+	if info, found := getExtraLevels()[lvl]; found {
+		return info
+	}
+	name := fmt.Sprintf("Level(%d)", lvl)
+	return &LevelInfo{Name: name, JSON: name, json: jsonString(name), Tag: "?", text: "???"}
+}
+
+// String returns the string representation of the level.
+func (l Level) String() string {
+	if l >= Debug && int(l) < len(LevelToStrA) {
+		return LevelToStrA[l]
+	}
+	return extraInfo(l).Name
+}
+
+// jsonName returns the quoted JSON level.
+func (l Level) jsonName() string {
+	if isBuiltin(l) {
+		return LevelToJSON[l]
+	}
+	return extraInfo(l).json
+}
+
+// color returns the level's color (empty if not in color mode).
+func (l Level) color() string {
+	if isBuiltin(l) {
+		return LevelToColor[l]
+	}
+	if !Color {
+		return ""
+	}
+	return extraInfo(l).Color
+}
+
+// tag returns the "[X]" text mode prefix.
+func (l Level) tag() string {
+	if l >= Debug && int(l) < len(LevelToStrA) {
+		return "[" + LevelToStrA[l][0:1] + "]"
+	}
+	return "[" + extraInfo(l).Tag + "]"
+}
+
+// text returns the 3 letters color mode name.
+func (l Level) text() string {
+	if isBuiltin(l) {
+		return LevelToText[l]
+	}
+	return extraInfo(l).text
+}
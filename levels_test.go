@@ -0,0 +1,157 @@
+// Copyright 2026 Fortio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log // import "fortio.org/log"
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// unregisterLevel undoes RegisterLevel, for tests.
+func unregisterLevel(lvl Level) {
+	info := getExtraLevels()[lvl]
+	updated := make(map[Level]*LevelInfo)
+	for l, i := range getExtraLevels() {
+		if l != lvl {
+			updated[l] = i
+		}
+	}
+	extraLevels.Store(updated)
+	lookup := getLevelLookup()
+	newLookup := &levelLookup{byName: copyLevelMap(lookup.byName, 0), byJSON: copyLevelMap(lookup.byJSON, 0)}
+	delete(newLookup.byName, info.Name)
+	delete(newLookup.byName, strings.ToLower(info.Name))
+	delete(newLookup.byJSON, info.JSON)
+	levelLookups.Store(newLookup)
+	delete(JSONStringLevelToLevel, info.JSON)
+}
+
+func TestTraceAndCustomLevels(t *testing.T) {
+	Fine := Level(-5)
+	Audit := Level(10)
+	if err := RegisterLevel(Fine, LevelInfo{Name: "Fine", JSON: "fine", Color: ANSIColors.Blue, Tag: "N"}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer unregisterLevel(Fine)
+	if err := RegisterLevel(Audit, LevelInfo{Name: "Audit", JSON: "audit", Color: ANSIColors.White, Tag: "A"}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer unregisterLevel(Audit)
+	for _, bad := range []struct {
+		lvl  Level
+		info LevelInfo
+	}{
+		{Info, LevelInfo{Name: "Info2", JSON: "info2", Tag: "I"}},
+		{-1, LevelInfo{Name: "Minus1", JSON: "minus1", Tag: "M"}},
+		{20, LevelInfo{Name: "Trace", JSON: "trace2", Tag: "T"}},
+		{20, LevelInfo{Name: "INFO", JSON: "info2", Tag: "I"}},
+		{20, LevelInfo{Name: "Trace2", JSON: "trace", Tag: "T"}},
+		{20, LevelInfo{Name: "Verbose2", JSON: "verbose", Tag: "V"}},
+		{20, LevelInfo{Name: "NoTag", JSON: "notag"}},
+		{Audit, LevelInfo{Name: "Audit2", JSON: "audit2", Tag: "A"}},
+	} {
+		if err := RegisterLevel(bad.lvl, bad.info); err == nil {
+			t.Errorf("expected error registering %d %+v", bad.lvl, bad.info)
+		}
+	}
+	names := strings.Join(LevelNames(), ",")
+	if names != "Fine,Trace,Debug,Verbose,Info,Warning,Error,Critical" {
+		t.Errorf("unexpected level names %s", names)
+	}
+	if _, err := ValidateLevel("x"); err == nil || !strings.Contains(err.Error(), "[Fine Trace Debug Verbose Info Warning Error Critical Fatal Audit]") {
+		t.Errorf("unexpected validation error %v", err)
+	}
+	for name, expected := range map[string]Level{"trace": Trace, "Trace": Trace, "fine": Fine, "Audit": Audit} {
+		if lvl, err := ValidateLevel(name); err != nil || lvl != expected {
+			t.Errorf("unexpected level for %s: %v %v", name, lvl, err)
+		}
+	}
+	// The exported mapping also has Trace and the custom levels.
+	if JSONStringLevelToLevel["verbose"] != Verbose || JSONStringLevelToLevel["trace"] != Trace ||
+		JSONStringLevelToLevel["audit"] != Audit || len(JSONStringLevelToLevel) != 10 {
+		t.Errorf("unexpected json level mapping %v", JSONStringLevelToLevel)
+	}
+	for name, expected := range map[string]Level{"verbose": Verbose, "trace": Trace, "audit": Audit, "dbug": Debug} {
+		if lvl, ok := LevelByJSON(name); !ok || lvl != expected {
+			t.Errorf("unexpected level for json %s: %v %v", name, lvl, ok)
+		}
+	}
+	if _, ok := LevelByJSON("trce"); ok {
+		t.Errorf("trce isn't a json level")
+	}
+	Config.LogFileAndLine = false
+	Config.JSON = true
+	Config.NoTimestamp = true
+	Config.GoroutineID = false
+	var b bytes.Buffer
+	w := bufio.NewWriter(&b)
+	SetOutput(w)
+	SetLogLevelQuiet(Info)
+	Tracef("not logged")
+	if prev := SetLogLevel(Trace); prev != Info || GetLogLevel() != Trace || !LogTrace() {
+		t.Errorf("unexpected level change %v -> %v", prev, GetLogLevel())
+	}
+	Tracef("trace %d", 1)
+	Logf(Fine, "not logged")
+	S(Audit, "audit", Str("user", "x"))
+	SetLogLevel(Audit) // not allowed, above Critical.
+	SetLogLevel(Fine)
+	Logf(Fine, "fine %d", 2)
+	Config.JSON = false
+	prevPrefix := Config.LogPrefix
+	Config.LogPrefix = "> "
+	SetFlags(0)
+	Tracef("trace in text")
+	S(Audit, "audit in text")
+	SetLogLevelQuiet(Info)
+	Config.JSON = true
+	Config.LogPrefix = prevPrefix
+	w.Flush()
+	actual := b.String()
+	expected := `{"level":"info","msg":"Log level is now -2 Trace (was 2 Info)"}
+{"level":"trace","msg":"trace 1"}
+{"level":"audit","msg":"audit","user":"x"}
+{"level":"err","msg":"SetLogLevel called with level 10 higher than Critical!"}
+{"level":"info","msg":"Log level is now -5 Fine (was -2 Trace)"}
+{"level":"fine","msg":"fine 2"}
+[T]> trace in text
+[A]> audit in text
+`
+	if actual != expected {
+		t.Errorf("unexpected:\n%s\nvs:\n%s\n", actual, expected)
+	}
+	Config.GoroutineID = true
+}
+
+func TestRegisterLevelConcurrentLookups(t *testing.T) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			_, _ = ValidateLevel("info")
+			_, _ = LevelByJSON("warn")
+		}
+	}()
+	if err := RegisterLevel(-10, LevelInfo{Name: "Finest", JSON: "finest", Tag: "F"}); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	<-done
+	if lvl, err := ValidateLevel("finest"); err != nil || lvl != -10 {
+		t.Errorf("unexpected level %v %v", lvl, err)
+	}
+	unregisterLevel(-10)
+}
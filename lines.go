@@ -0,0 +1,106 @@
+// Copyright 2026 Fortio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Logging of (unstructured) text line by line, e.g. captured output.
+
+package log // import "fortio.org/log"
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// MaxLineLength is the default maximum length of the lines logged by LineWriter, longer lines are split
+// into several entries.
+const MaxLineLength = 16 * 1024
+
+// LineWriter is an io.WriteCloser logging each line written to it as an entry, see NewWriter.
+type LineWriter struct {
+	// Lines longer than this are split into several entries. Can only be changed before the first Write.
+	MaxLineLength int
+	level         Level
+	attrs         []KeyVal
+	mu            sync.Mutex
+	buf           []byte
+}
+
+// NewWriter returns an io.WriteCloser, for libraries or tools only accepting an io.Writer, logging
+// each line written to it at the given level with the attributes. Partial lines are buffered until
+// completed by a newline (or Close), the newline and a preceding carriage return are stripped and
+// empty lines are skipped.
+func NewWriter(level Level, attrs ...KeyVal) *LineWriter {
+	return &LineWriter{MaxLineLength: MaxLineLength, level: level, attrs: attrs}
+}
+
+// Write logs the complete lines in p and buffers the remaining partial line. It always succeeds.
+func (w *LineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	n := len(p)
+	for len(p) > 0 {
+		idx := bytes.IndexByte(p, '\n')
+		if idx < 0 {
+			w.buf = append(w.buf, p...)
+			break
+		}
+		w.buf = append(w.buf, p[:idx]...)
+		p = p[idx+1:]
+		w.flushLocked(true)
+	}
+	w.flushLocked(false)
+	return n, nil
+}
+
+// Close logs the buffered partial line, if any. The writer can still be used afterwards.
+func (w *LineWriter) Close() error {
+	w.mu.Lock()
+	w.flushLocked(true)
+	w.mu.Unlock()
+	return nil
+}
+
+// flushLocked logs the buffer in chunks of at most MaxLineLength and the remainder when all is set.
+func (w *LineWriter) flushLocked(all bool) {
+	maxLen := w.MaxLineLength
+	if maxLen <= 0 {
+		maxLen = MaxLineLength
+	}
+	line := w.buf
+	for len(line) >= maxLen {
+		w.log(line[:maxLen])
+		line = line[maxLen:]
+	}
+	if all {
+		if len(line) > 0 && line[len(line)-1] == '\r' {
+			line = line[:len(line)-1]
+		}
+		if len(line) > 0 {
+			w.log(line)
+		}
+		line = nil
+	}
+	w.buf = append(w.buf[:0], line...)
+}
+
+func (w *LineWriter) log(line []byte) {
+	s(w.level, false, Config.JSON, string(line), w.attrs...)
+}
+
+// logLines logs each line read from r (until EOF or error) at the given level with the attributes.
+func logLines(r io.Reader, lvl Level, attrs ...KeyVal) {
+	w := NewWriter(lvl, attrs...)
+	_, _ = io.Copy(w, r)
+	w.Close()
+}
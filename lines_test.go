@@ -0,0 +1,62 @@
+// Copyright 2026 Fortio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log // import "fortio.org/log"
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+)
+
+func TestNewWriter(t *testing.T) {
+	SetLogLevel(Info)
+	Config.LogFileAndLine = false
+	Config.JSON = true
+	Config.NoTimestamp = true
+	Config.GoroutineID = false
+	var b bytes.Buffer
+	w := bufio.NewWriter(&b)
+	SetOutput(w)
+	var lw io.WriteCloser = NewWriter(Info, Str("lib", "x"))
+	fmt.Fprint(lw, "partial")
+	fmt.Fprint(lw, " line\r\nsecond\n\nthird")
+	n, err := lw.Write([]byte(" end\nunterminated"))
+	if n != 17 || err != nil {
+		t.Errorf("unexpected write result %d %v", n, err)
+	}
+	lw.Close()
+	debug := NewWriter(Debug)
+	fmt.Fprintln(debug, "not logged")
+	long := NewWriter(Warning)
+	long.MaxLineLength = 4
+	fmt.Fprint(long, "0123456789")
+	fmt.Fprint(long, "ab\n")
+	w.Flush()
+	actual := b.String()
+	expected := `{"level":"info","msg":"partial line","lib":"x"}
+{"level":"info","msg":"second","lib":"x"}
+{"level":"info","msg":"third end","lib":"x"}
+{"level":"info","msg":"unterminated","lib":"x"}
+{"level":"warn","msg":"0123"}
+{"level":"warn","msg":"4567"}
+{"level":"warn","msg":"89ab"}
+`
+	if actual != expected {
+		t.Errorf("unexpected:\n%s\nvs:\n%s\n", actual, expected)
+	}
+	Config.GoroutineID = true
+}
@@ -42,7 +42,7 @@ import (
 	"fortio.org/struct2env"
 )
 
-// Level is the level of logging (0 Debug -> 6 Fatal).
+// Level is the level of logging (0 Debug -> 6 Fatal, see also Trace and RegisterLevel).
 type Level int8
 
 // Log levels. Go can't have variable and function of the same name so we keep
@@ -69,7 +69,7 @@ type LogConfig struct {
 	LogPrefix      string    // "Prefix to log lines before logged messages
 	LogFileAndLine bool      // Logs filename and line number of callers to log.
 	FatalPanics    bool      // If true, log.Fatalf will panic (stack trace) instead of just exit 1
-	FatalExit      func(int) `env:"-"` // Function to call upon log.Fatalf. e.g. os.Exit.
+	FatalExit      func(int) `env:"-" json:"-"` // Function to call upon log.Fatalf. e.g. os.Exit.
 	JSON           bool      // If true, log in structured JSON format instead of text (but see ConsoleColor).
 	NoTimestamp    bool      // If true, don't log timestamp in json.
 	ConsoleColor   bool      // If true and we detect console output (not redirected), use text+color mode.
@@ -89,6 +89,22 @@ type LogConfig struct {
 	// False will disable SetDefaultsForClientTools() so if you want it despite a redirect
 	// you can set ConsoleLogging to true artificially.
 	ConsoleLogging bool `env:"-"`
+	// If true (the default), JSON output is always valid JSON (e.g. parseable back into JSONEntry
+	// by encoding/json): non finite floats (NaN, Inf) are logged as strings and invalid UTF-8 in
+	// messages, keys and values is replaced. If false, cheaper go %q quoting is used instead.
+	StrictJSON bool
+	// If true (the default), control characters like newlines or the ESC of ANSI sequences are escaped
+	// in text and color modes messages and keys, so logged user input can't forge lines or repaint the terminal.
+	EscapeText bool
+	// If true, when EscapeText is on, newlines in messages are kept and followed by a tab (indented)
+	// instead of being escaped as \n.
+	IndentMultiLine bool
+	// How Duration() attributes are serialized in JSON: "seconds" (float, the default), "ms" (float
+	// milliseconds) or "string" (e.g "1.5s"). See DurationSeconds, DurationMillis and DurationString.
+	DurationFormat string
+	// Maximum time the OnFatal hooks and the flushing of the sinks can take before Fatalf exits (0 for
+	// no limit).
+	FatalFlushTimeout time.Duration
 }
 
 // DefaultConfig() returns the default initial configuration for the logger, best suited
@@ -106,12 +122,18 @@ func DefaultConfig() *LogConfig {
 		ConsoleColor:              true,
 		GoroutineID:               true,
 		CombineRequestAndResponse: true,
+		DurationFormat:            DurationSeconds,
+		StrictJSON:                true,
+		EscapeText:                true,
+		FatalFlushTimeout:         5 * time.Second,
 	}
 }
 
 var (
 	Config = DefaultConfig()
 	// LevelToStrA is used for dynamic flag setting as strings and validation.
+	// This and the other LevelTo... slices only have the built-in levels, from Debug: use the Level methods
+	// (e.g. String()) for Trace and the custom levels (see RegisterLevel).
 	LevelToStrA = []string{
 		"Debug",
 		"Verbose",
@@ -121,14 +143,13 @@ var (
 		"Critical",
 		"Fatal",
 	}
-	levelToStrM   map[string]Level
 	levelInternal int32
 	// LevelToJSON is used for JSON logging.
 	LevelToJSON = []string{
 		// matching https://github.com/grafana/grafana/blob/main/docs/sources/explore/logs-integration.md
 		// adding the "" around to save processing when generating json. using short names to save some bytes.
 		"\"dbug\"",
-		"\"trace\"",
+		"\"verbose\"", // was "trace" before the Trace level was added.
 		"\"info\"",
 		"\"warn\"",
 		"\"err\"",
@@ -137,7 +158,9 @@ var (
 		"\"info\"", // For Printf / NoLevel JSON output
 	}
 	// JSONStringLevelToLevel is the reverse mapping of level string used in JSON to Level. Used by https://github.com/fortio/logc
-	// to interpret and colorize pre existing JSON logs.
+	// to interpret and colorize pre existing JSON logs. It also has Trace and the custom levels (see RegisterLevel),
+	// which are outside of the LevelTo... slices: use the Level methods (e.g. String()) rather than indexing those.
+	// LevelByJSON is the same lookup but safe to use concurrently with RegisterLevel.
 	JSONStringLevelToLevel map[string]Level
 )
 
@@ -203,10 +226,10 @@ func (l *JSONEntry) Time() time.Time {
 }
 
 func intToLevel(i int) Level {
-	if i < 0 || i >= len(LevelToStrA) {
+	if i < math.MinInt8 || i > math.MaxInt8 || Level(i) == NoLevel || !isKnown(Level(i)) {
 		return -1
 	}
-	return Level(i) //nolint:gosec // we just checked above.
+	return Level(i)
 }
 
 //nolint:gochecknoinits // needed
@@ -215,7 +238,7 @@ func init() {
 		SetOutput(os.Stdout) // this could also be invalid too, but... we tried.
 	}
 	setLevel(Info) // starting value
-	levelToStrM = make(map[string]Level, 2*len(LevelToStrA))
+	levelToStrM := make(map[string]Level, 2*len(LevelToStrA))
 	JSONStringLevelToLevel = make(map[string]Level, len(LevelToJSON)-1) // -1 to not reverse info to NoLevel
 	for l, name := range LevelToStrA {
 		// Allow both -loglevel Verbose and -loglevel verbose ...
@@ -227,7 +250,10 @@ func init() {
 		// strip the quotes around
 		JSONStringLevelToLevel[name[1:len(name)-1]] = intToLevel(l)
 	}
+	levelLookups.Store(&levelLookup{byName: levelToStrM, byJSON: copyLevelMap(JSONStringLevelToLevel, 1)})
+	registerBuiltinLevels()
 	log.SetFlags(log.Ltime)
+	log.SetOutput(stdWriter{jWriter.w}) // os.Stdout if stderr isn't valid, see above.
 	configFromEnv()
 	SetColorMode()
 	jWriter.buf.Grow(2048)
@@ -253,17 +279,12 @@ func setLevel(lvl Level) {
 	atomic.StoreInt32(&levelInternal, int32(lvl))
 }
 
-// String returns the string representation of the level.
-func (l Level) String() string {
-	return LevelToStrA[l]
-}
-
 // ValidateLevel returns error if the level string is not valid.
 func ValidateLevel(str string) (Level, error) {
 	var lvl Level
 	var ok bool
-	if lvl, ok = levelToStrM[str]; !ok {
-		return -1, fmt.Errorf("should be one of %v", LevelToStrA)
+	if lvl, ok = getLevelLookup().byName[str]; !ok {
+		return -1, fmt.Errorf("should be one of %v", allLevelNames())
 	}
 	return lvl, nil
 }
@@ -276,7 +297,7 @@ func LoggerStaticFlagSetup(names ...string) {
 		names = []string{"loglevel"}
 	}
 	for _, name := range names {
-		flag.Var(&flagV, name, fmt.Sprintf("log `level`, one of %v", LevelToStrA))
+		flag.Var(&flagV, name, fmt.Sprintf("log `level`, one of %v", allLevelNames()))
 	}
 }
 
@@ -337,7 +358,7 @@ func SetLogLevelQuiet(lvl Level) Level {
 // if logChange is true the level change is logged.
 func setLogLevel(lvl Level, logChange bool) Level {
 	prev := GetLogLevel()
-	if lvl < Debug {
+	if lvl < Debug && !isKnown(lvl) {
 		logUnconditionalf(Config.LogFileAndLine, Error, "SetLogLevel called with level %d lower than Debug!", lvl)
 		return -1
 	}
@@ -362,7 +383,8 @@ func setLogLevel(lvl Level, logChange bool) Level {
 // LOGGER_LOG_PREFIX, LOGGER_LOG_FILE_AND_LINE, LOGGER_FATAL_PANICS,
 // LOGGER_JSON, LOGGER_NO_TIMESTAMP, LOGGER_CONSOLE_COLOR, LOGGER_CONSOLE_COLOR
 // LOGGER_FORCE_COLOR, LOGGER_GOROUTINE_ID, LOGGER_COMBINE_REQUEST_AND_RESPONSE,
-// LOGGER_LEVEL.
+// LOGGER_LEVEL, LOGGER_IGNORE_CLI_MODE, LOGGER_STRICT_JSON, LOGGER_ESCAPE_TEXT,
+// LOGGER_INDENT_MULTI_LINE, LOGGER_DURATION_FORMAT, LOGGER_FATAL_FLUSH_TIMEOUT.
 func EnvHelp(w io.Writer) {
 	res, _ := struct2env.StructToEnvVars(Config)
 	str := struct2env.ToShellWithPrefix(EnvPrefix, res, true)
@@ -386,7 +408,15 @@ func Log(lvl Level) bool {
 
 // LevelByName returns the LogLevel by its name.
 func LevelByName(str string) Level {
-	return levelToStrM[str]
+	return getLevelLookup().byName[str]
+}
+
+// LevelByJSON returns the level for the JSON level string (e.g. "warn"), including Trace and
+// the custom levels, and false if it's not a known one. Unlike JSONStringLevelToLevel, it can be
+// used concurrently with RegisterLevel.
+func LevelByJSON(str string) (Level, bool) {
+	lvl, found := getLevelLookup().byJSON[str]
+	return lvl, found
 }
 
 // Logf logs with format at the given level.
@@ -415,8 +445,11 @@ func jsonWrite(msg string) {
 
 func jsonWriteBytes(msg []byte) {
 	jWriter.mutex.Lock()
-	_, _ = jWriter.w.Write(msg) // if we get errors while logging... can't quite ... log errors
+	exit := outputWrite(jWriter.w, msg) // errors can't quite be logged, see WriteErrorPolicy.
 	jWriter.mutex.Unlock()
+	if exit {
+		brokenPipeExit()
+	}
 }
 
 // TimeToTS converts a time.Time to a float64 timestamp (seconds since epoch at microsecond resolution).
@@ -456,6 +489,9 @@ func logPrintf(lvl Level, format string, rest ...any) {
 	if !Log(lvl) {
 		return
 	}
+	if countingCallSites() {
+		countCaller(2)
+	}
 	if Config.JSON && !Config.LogFileAndLine && !Color && !Config.NoTimestamp && !Config.GoroutineID && len(rest) == 0 {
 		logSimpleJSON(lvl, format)
 		return
@@ -464,6 +500,9 @@ func logPrintf(lvl Level, format string, rest ...any) {
 }
 
 func logSimpleJSON(lvl Level, msg string) {
+	countLevel(lvl)
+	msg = redactMsg(msg)
+	dispatch(lvl, "", 0, msg, nil)
 	jWriter.mutex.Lock()
 	jWriter.buf.Reset()
 	jWriter.buf.WriteString("{\"ts\":")
@@ -471,14 +510,18 @@ func logSimpleJSON(lvl Level, msg string) {
 	jWriter.tsBuf = jWriter.tsBuf[:0] // reset the slice
 	jWriter.tsBuf = strconv.AppendFloat(jWriter.tsBuf, t, 'f', 6, 64)
 	jWriter.buf.Write(jWriter.tsBuf)
-	fmt.Fprintf(&jWriter.buf, ",\"level\":%s,\"msg\":%q}\n",
-		LevelToJSON[lvl],
-		msg)
-	_, _ = jWriter.w.Write(jWriter.buf.Bytes())
+	fmt.Fprintf(&jWriter.buf, ",\"level\":%s,\"msg\":%s}\n",
+		lvl.jsonName(),
+		jsonString(msg))
+	exit := outputWrite(jWriter.w, jWriter.buf.Bytes())
 	jWriter.mutex.Unlock()
+	if exit {
+		brokenPipeExit()
+	}
 }
 
 func logUnconditionalf(logFileAndLine bool, lvl Level, format string, rest ...any) {
+	countLevel(lvl)
 	prefix := Config.LogPrefix
 	if prefix == "" {
 		prefix = " "
@@ -487,40 +530,54 @@ func logUnconditionalf(logFileAndLine bool, lvl Level, format string, rest ...an
 	if lvl == NoLevel {
 		prefix = ""
 	}
-	if logFileAndLine { //nolint:nestif // tiny bit complicated yes.
-		_, file, line, _ := runtime.Caller(3)
+	file, line := "", 0
+	if logFileAndLine {
+		_, file, line, _ = runtime.Caller(3)
 		file = file[strings.LastIndex(file, "/")+1:]
+	}
+	if len(getSinks()) > 0 {
+		msg := format
+		if len(rest) != 0 {
+			msg = fmt.Sprintf(format, rest...)
+		}
+		dispatch(lvl, file, line, redactMsg(msg), nil)
+	}
+	// message for the text and color modes.
+	textMsg := func() string {
+		return textEscape(redactMsg(fmt.Sprintf(format, rest...)))
+	}
+	if logFileAndLine { //nolint:nestif // tiny bit complicated yes.
 		switch {
 		case Color:
 			jsonWrite(fmt.Sprintf("%s%s%s %s:%d%s%s%s%s\n",
 				colorTimestamp(), colorGID(), ColorLevelToStr(lvl),
-				file, line, prefix, LevelToColor[lvl], fmt.Sprintf(format, rest...), Colors.Reset))
+				file, line, prefix, lvl.color(), textMsg(), Colors.Reset))
 		case Config.JSON:
-			jsonWrite(fmt.Sprintf("{%s\"level\":%s,%s\"file\":%q,\"line\":%d,\"msg\":%q}\n",
-				jsonTimestamp(), LevelToJSON[lvl], jsonGID(), file, line, fmt.Sprintf(format, rest...)))
+			jsonWrite(fmt.Sprintf("{%s\"level\":%s,%s\"file\":%s,\"line\":%d,\"msg\":%s}\n",
+				jsonTimestamp(), lvl.jsonName(), jsonGID(), jsonString(file), line, jsonString(redactMsg(fmt.Sprintf(format, rest...)))))
 		default:
 			if lvl != NoLevel {
-				lvl1Char = "[" + LevelToStrA[lvl][0:1] + "]"
+				lvl1Char = lvl.tag()
 			}
-			log.Print(lvl1Char, " ", file, ":", line, prefix, fmt.Sprintf(format, rest...))
+			stdPrint(lvl1Char, " ", file, ":", line, prefix, textMsg())
 		}
 	} else {
 		switch {
 		case Color:
 			jsonWrite(fmt.Sprintf("%s%s%s%s%s%s%s\n",
-				colorTimestamp(), colorGID(), ColorLevelToStr(lvl), prefix, LevelToColor[lvl],
-				fmt.Sprintf(format, rest...), Colors.Reset))
+				colorTimestamp(), colorGID(), ColorLevelToStr(lvl), prefix, lvl.color(),
+				textMsg(), Colors.Reset))
 		case Config.JSON:
 			if len(rest) != 0 {
 				format = fmt.Sprintf(format, rest...)
 			}
-			jsonWrite(fmt.Sprintf("{%s\"level\":%s,%s\"msg\":%q}\n",
-				jsonTimestamp(), LevelToJSON[lvl], jsonGID(), format))
+			jsonWrite(fmt.Sprintf("{%s\"level\":%s,%s\"msg\":%s}\n",
+				jsonTimestamp(), lvl.jsonName(), jsonGID(), jsonString(redactMsg(format))))
 		default:
 			if lvl != NoLevel {
-				lvl1Char = "[" + LevelToStrA[lvl][0:1] + "]"
+				lvl1Char = lvl.tag()
 			}
-			log.Print(lvl1Char, prefix, fmt.Sprintf(format, rest...))
+			stdPrint(lvl1Char, prefix, textMsg())
 		}
 	}
 }
@@ -533,7 +590,7 @@ func Printf(format string, rest ...any) {
 // SetOutput sets the output to a different writer (forwards to system logger).
 func SetOutput(w io.Writer) {
 	jWriter.w = w
-	log.SetOutput(w)
+	log.SetOutput(stdWriter{w})
 	SetColorMode() // Resets color mode boolean (and console logging detection)
 }
 
@@ -544,6 +601,11 @@ func SetFlags(f int) {
 
 // -- would be nice to be able to create those in a loop instead of copypasta:
 
+// Tracef logs if Trace level is on.
+func Tracef(format string, rest ...any) {
+	logPrintf(Trace, format, rest...)
+}
+
 // Debugf logs if Debug level is on.
 func Debugf(format string, rest ...any) {
 	logPrintf(Debug, format, rest...)
@@ -574,13 +636,17 @@ func Critf(format string, rest ...any) {
 	logPrintf(Critical, format, rest...)
 }
 
-// Fatalf logs if Warning level is on and panics or exits.
+// Fatalf logs if Warning level is on and panics or exits (with code 1),
+// after running the OnFatal hooks and flushing the sinks (see CloseSinks).
 func Fatalf(format string, rest ...any) {
 	logPrintf(Fatal, format, rest...)
-	if Config.FatalPanics {
-		panic("aborting...")
-	}
-	Config.FatalExit(1)
+	fatalExit(1)
+}
+
+// FatalfCode is Fatalf with the given exit code.
+func FatalfCode(code int, format string, rest ...any) {
+	logPrintf(Fatal, format, rest...)
+	fatalExit(code)
 }
 
 // FErrf logs a fatal error and returns 1.
@@ -596,16 +662,30 @@ func Fatalf(format string, rest ...any) {
 //
 // so they can be tested with testscript.
 // See https://github.com/fortio/delta/ for an example.
+// Like for Fatalf, the OnFatal hooks are run and the sinks flushed.
 func FErrf(format string, rest ...any) int {
 	logPrintf(Fatal, format, rest...)
+	runFatalHooks()
 	return 1
 }
 
+// FErrfCode is FErrf returning the given exit code.
+func FErrfCode(code int, format string, rest ...any) int {
+	logPrintf(Fatal, format, rest...)
+	runFatalHooks()
+	return code
+}
+
 // LogDebug shortcut for fortio.Log(fortio.Debug).
 func LogDebug() bool { //nolint:revive // yeah no a bit of stutter is fine here.
 	return Log(Debug)
 }
 
+// LogTrace shortcut for fortio.Log(fortio.Trace).
+func LogTrace() bool { //nolint:revive // yeah no a bit of stutter is fine here.
+	return Log(Trace)
+}
+
 // LogVerbose shortcut for fortio.Log(fortio.Verbose).
 func LogVerbose() bool { //nolint:revive // yeah no a bit of stutter is fine here.
 	return Log(Verbose)
@@ -632,6 +712,9 @@ func Logger() LoggerI {
 
 // Somewhat slog compatible/style logger
 
+// JSON serialization of nil/nil pointers.
+const nullString = "null"
+
 type KeyVal struct {
 	Key      string
 	StrValue string
@@ -678,6 +761,46 @@ func (v *KeyVal) StringValue() string {
 	return v.StrValue
 }
 
+// LogValuer is implemented by values that want to defer computing what actually gets logged.
+// LogValue() is only called once the level check passed and the entry is being emitted,
+// and at most once per entry.
+type LogValuer interface {
+	LogValue() any
+}
+
+// LazyValue is a LogValuer function, see [Lazy].
+type LazyValue func() any
+
+// LogValue calls the function (nil logs null), see LogValuer.
+func (f LazyValue) LogValue() any {
+	if f == nil {
+		return nil
+	}
+	return f()
+}
+
+// Lazy returns an attribute whose value is only computed (by calling f) if and when the
+// entry is actually logged. Use it for expensive values (dumps, serializations, etc).
+func Lazy(key string, f func() any) KeyVal {
+	return Any(key, LazyValue(f))
+}
+
+// Max number of LogValuer returning LogValuer we follow (in case of loops).
+const maxLogValuerDepth = 100
+
+// resolveLogValuer calls LogValue() until the result is no longer a LogValuer.
+func resolveLogValuer(lv LogValuer) any {
+	var v any = lv
+	for i := 0; i < maxLogValuerDepth; i++ {
+		lv, ok := v.(LogValuer)
+		if !ok {
+			return v
+		}
+		v = lv.LogValue()
+	}
+	return fmt.Sprintf("LogValue() loop (more than %d levels)", maxLogValuerDepth)
+}
+
 type ValueTypes interface{ any }
 
 type ValueType[T ValueTypes] struct {
@@ -696,6 +819,33 @@ func Any[T ValueTypes](key string, value T) KeyVal {
 	}
 }
 
+// appendAttrs formats the attributes, in text and color mode groups are flattened
+// using keyPrefix (dotted group names).
+// Values are redacted if r isn't nil.
+func appendAttrs(buf *strings.Builder, format string, json bool, keyPrefix string, attrs []KeyVal, r *Redactor) {
+	for i := range attrs {
+		// by index so the (lazily) computed value is cached and only computed once.
+		key := keyPrefix + attrs[i].Key
+		var value string
+		if json {
+			value = redactedJSONValue(r, "", &attrs[i])
+			key = jsonString(key)
+		} else {
+			if g, isGroup := attrs[i].Value.(groupValue); isGroup && !r.IsSensitiveKey(key) {
+				appendAttrs(buf, format, json, key+".", g, r)
+				continue
+			}
+			value = attrs[i].textValue()
+			if r != nil {
+				value = r.redactValue(key, value)
+			}
+			key = textEscape(key)
+			value = textEscape(value)
+		}
+		buf.WriteString(fmt.Sprintf(format, key, value))
+	}
+}
+
 // S logs a message of the given level with additional attributes.
 func S(lvl Level, msg string, attrs ...KeyVal) {
 	s(lvl, Config.LogFileAndLine, Config.JSON, msg, attrs...)
@@ -705,22 +855,44 @@ func s(lvl Level, logFileAndLine bool, json bool, msg string, attrs ...KeyVal) {
 	if !Log(lvl) {
 		return
 	}
-	if Config.JSON && !Config.LogFileAndLine && !Color && !Config.NoTimestamp && !Config.GoroutineID && len(attrs) == 0 {
+	if countingCallSites() {
+		countCaller(2)
+	}
+	emit(nil, lvl, logFileAndLine, json, msg, attrs...)
+}
+
+// emit formats and writes the entry (unconditionally) to the output or into b if not nil.
+// It must be called at the same depth as from S() for the caller's file and line to be correct.
+func emit(b *logBuffer, lvl Level, logFileAndLine bool, json bool, msg string, attrs ...KeyVal) {
+	if b == nil && Config.JSON && !Config.LogFileAndLine && !Color && !Config.NoTimestamp && !Config.GoroutineID && len(attrs) == 0 {
 		logSimpleJSON(lvl, msg)
 		return
 	}
+	file, line := "", 0
+	if logFileAndLine {
+		_, file, line, _ = runtime.Caller(3)
+		file = file[strings.LastIndex(file, "/")+1:]
+	}
+	emitAt(b, lvl, file, line, json, msg, attrs...)
+}
+
+// emitAt is emit() with the caller's file and line already determined (not logged if file is empty).
+func emitAt(b *logBuffer, lvl Level, file string, line int, json bool, msg string, attrs ...KeyVal) {
 	buf := strings.Builder{}
 	var format string
 	switch {
 	case Color:
-		format = Colors.Reset + ", " + Colors.Blue + "%s" + Colors.Reset + "=" + LevelToColor[lvl] + "%v"
+		format = Colors.Reset + ", " + Colors.Blue + "%s" + Colors.Reset + "=" + lvl.color() + "%v"
 	case json:
-		format = ",%q:%s"
+		format = ",%s:%s"
 	default:
 		format = ", %s=%s"
 	}
-	for _, attr := range attrs {
-		buf.WriteString(fmt.Sprintf(format, attr.Key, attr.StringValue()))
+	r := GetRedactor()
+	msg = r.scrub(msg, false)
+	appendAttrs(&buf, format, json && !Color, "", attrs, r)
+	if b == nil { // buffered entries below the log level don't go to the sinks.
+		dispatch(lvl, file, line, msg, attrs)
 	}
 	// TODO share code with log.logUnconditionalf yet without extra locks or allocations/buffers?
 	prefix := Config.LogPrefix
@@ -731,32 +903,33 @@ func s(lvl Level, logFileAndLine bool, json bool, msg string, attrs ...KeyVal) {
 	if lvl == NoLevel {
 		prefix = ""
 	} else {
-		lvl1Char = "[" + LevelToStrA[lvl][0:1] + "]"
+		lvl1Char = lvl.tag()
 	}
-	if logFileAndLine {
-		_, file, line, _ := runtime.Caller(2)
-		file = file[strings.LastIndex(file, "/")+1:]
+	if !json || Color {
+		msg = textEscape(msg)
+	}
+	if file != "" {
 		switch {
 		case Color:
-			jsonWrite(fmt.Sprintf("%s%s%s %s:%d%s%s%s%s%s\n",
+			b.write(lvl, fmt.Sprintf("%s%s%s %s:%d%s%s%s%s%s\n",
 				colorTimestamp(), colorGID(), ColorLevelToStr(lvl),
-				file, line, prefix, LevelToColor[lvl], msg, buf.String(), Colors.Reset))
+				file, line, prefix, lvl.color(), msg, buf.String(), Colors.Reset))
 		case json:
-			jsonWrite(fmt.Sprintf("{%s\"level\":%s,%s\"file\":%q,\"line\":%d,\"msg\":%q%s}\n",
-				jsonTimestamp(), LevelToJSON[lvl], jsonGID(), file, line, msg, buf.String()))
+			b.write(lvl, fmt.Sprintf("{%s\"level\":%s,%s\"file\":%s,\"line\":%d,\"msg\":%s%s}\n",
+				jsonTimestamp(), lvl.jsonName(), jsonGID(), jsonString(file), line, jsonString(msg), buf.String()))
 		default:
-			log.Print(lvl1Char, " ", file, ":", line, prefix, msg, buf.String())
+			b.print(lvl, lvl1Char, " ", file, ":", line, prefix, msg, buf.String())
 		}
 	} else {
 		switch {
 		case Color:
-			jsonWrite(fmt.Sprintf("%s%s%s%s%s%s%s%s\n",
-				colorTimestamp(), colorGID(), ColorLevelToStr(lvl), prefix, LevelToColor[lvl], msg, buf.String(), Colors.Reset))
+			b.write(lvl, fmt.Sprintf("%s%s%s%s%s%s%s%s\n",
+				colorTimestamp(), colorGID(), ColorLevelToStr(lvl), prefix, lvl.color(), msg, buf.String(), Colors.Reset))
 		case json:
-			jsonWrite(fmt.Sprintf("{%s\"level\":%s,\"msg\":%q%s}\n",
-				jsonTimestamp(), LevelToJSON[lvl], msg, buf.String()))
+			b.write(lvl, fmt.Sprintf("{%s\"level\":%s,\"msg\":%s%s}\n",
+				jsonTimestamp(), lvl.jsonName(), jsonString(msg), buf.String()))
 		default:
-			log.Print(lvl1Char, prefix, msg, buf.String())
+			b.print(lvl, lvl1Char, prefix, msg, buf.String())
 		}
 	}
 }
@@ -295,7 +295,7 @@ func TestLoggerJSON(t *testing.T) {
 	if err != nil {
 		t.Errorf("unexpected JSON deserialization error %v for %q", err, actual)
 	}
-	if e.Level != "trace" {
+	if e.Level != "verbose" {
 		t.Errorf("unexpected level %s", e.Level)
 	}
 	if e.Msg != "Test Verbose 0" {
@@ -339,7 +339,7 @@ func Test_LogS_JSON(t *testing.T) {
 	if err != nil {
 		t.Errorf("unexpected JSON deserialization error %v for %q", err, actual)
 	}
-	if e.Level != "trace" {
+	if e.Level != "verbose" {
 		t.Errorf("unexpected level %s", e.Level)
 	}
 	if e.Msg != "Test Verbose" {
@@ -671,7 +671,7 @@ func TestStaticFlagDefault(t *testing.T) {
 	flag.CommandLine.PrintDefaults()
 	s := b.String()
 	expected := "  -loglevel level\n" +
-		"    \tlog level, one of [Debug Verbose Info Warning Error Critical Fatal] " +
+		"    \tlog level, one of [Trace Debug Verbose Info Warning Error Critical Fatal] " +
 		"(default Warning)\n"
 	if !strings.HasPrefix(s, expected) {
 		t.Errorf("expected flag output to start with %q, got %q", expected, s)
@@ -883,6 +883,116 @@ func TestSerializationOfError(t *testing.T) {
 	}
 }
 
+func TestTextEscape(t *testing.T) {
+	SetLogLevelQuiet(Info)
+	Config.LogFileAndLine = false
+	Config.JSON = false
+	Config.LogPrefix = " "
+	Config.GoroutineID = false
+	var b bytes.Buffer
+	SetOutput(&b)
+	SetFlags(0)
+	evil := "user\n[E] forged line\r\x1b[2J\u009b\x7f\ttab"
+	Infof("got %s", evil)
+	S(Warning, "S "+evil, Str("key\n"+evil, evil))
+	Printf("printf escapes\nnewlines and \x1b[31m")
+	Infof("trailing newline removed\n")
+	Config.IndentMultiLine = true
+	Infof("multi\nline")
+	Config.IndentMultiLine = false
+	Config.EscapeText = false
+	Infof("raw\x1b")
+	Config.EscapeText = true
+	actual := b.String()
+	escaped := `user\n[E] forged line\r\x1b[2J\u009b\x7f` + "\ttab"
+	expected := "[I] got " + escaped + "\n" +
+		"[W] S " + escaped + `, key\n` + escaped + `="user\n[E] forged line\r\u001b[2J\u009b\x7f\ttab"` + "\n" +
+		`printf escapes\nnewlines and \x1b[31m` + "\n" +
+		"[I] trailing newline removed\n" +
+		"[I] multi\n\tline\n" +
+		"[I] raw\x1b\n"
+	if actual != expected {
+		t.Errorf("unexpected:\n%s\nvs:\n%s\n", actual, expected)
+	}
+	// Color mode
+	b.Reset()
+	Config.ForceColor = true
+	Config.NoTimestamp = true
+	SetOutput(&b)
+	Infof("color %s", "\x1b[2J")
+	S(Info, "color S \x1b[2J")
+	Config.ForceColor = false
+	SetOutput(&b)
+	actual = b.String()
+	expected = "\x1b[90m[\x1b[32mINF\x1b[90m] \x1b[32mcolor \\x1b[2J\x1b[0m\n" +
+		"\x1b[90m[\x1b[32mINF\x1b[90m] \x1b[32mcolor S \\x1b[2J\x1b[0m\n"
+	if actual != expected {
+		t.Errorf("unexpected:\n%q\nvs:\n%q\n", actual, expected)
+	}
+	Config.GoroutineID = true
+}
+
+type testLogValuer struct {
+	calls *int
+}
+
+func (v testLogValuer) LogValue() any {
+	*v.calls++
+	return map[string]any{"calls": *v.calls}
+}
+
+func TestLazy(t *testing.T) {
+	SetLogLevelQuiet(Info)
+	Config.LogFileAndLine = false
+	Config.JSON = true
+	Config.NoTimestamp = true
+	Config.GoroutineID = false
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	lazyCalls := 0
+	lazy := func() any {
+		lazyCalls++
+		return []any{"expensive", lazyCalls}
+	}
+	valuerCalls := 0
+	S(Debug, "not logged", Lazy("lazy", lazy), Any("valuer", testLogValuer{&valuerCalls}))
+	if lazyCalls != 0 || valuerCalls != 0 {
+		t.Errorf("expected no evaluation when not logging, got %d %d", lazyCalls, valuerCalls)
+	}
+	S(Info, "logged", Lazy("lazy", lazy), Any("valuer", testLogValuer{&valuerCalls}), Lazy("nil", nil))
+	if lazyCalls != 1 || valuerCalls != 1 {
+		t.Errorf("expected exactly one evaluation, got %d %d", lazyCalls, valuerCalls)
+	}
+	actual := buf.String()
+	expected := `{"level":"info","msg":"logged","lazy":["expensive",1],"valuer":{"calls":1},"nil":null}` + "\n"
+	if actual != expected {
+		t.Errorf("unexpected:\n%s\nvs:\n%s\n", actual, expected)
+	}
+	// Cached in the KeyVal.
+	kv := Lazy("lazy", lazy)
+	_ = kv.StringValue()
+	kvStr := kv.StringValue()
+	if lazyCalls != 2 || kvStr != `["expensive",2]` {
+		t.Errorf("unexpected %d calls, value %s", lazyCalls, kvStr)
+	}
+	Config.GoroutineID = true
+}
+
+type loopValuer struct{}
+
+func (loopValuer) LogValue() any {
+	return loopValuer{}
+}
+
+func TestLogValuerLoop(t *testing.T) {
+	kv := Any("loop", loopValuer{})
+	kvStr := kv.StringValue()
+	expected := `"LogValue() loop (more than 100 levels)"`
+	if kvStr != expected {
+		t.Errorf("unexpected:\n%s\nvs:\n%s\n", kvStr, expected)
+	}
+}
+
 func TestEnvHelp(t *testing.T) {
 	Config.ConsoleLogging = true // pretend we aren't redirected.
 	SetDefaultsForClientTools()
@@ -905,6 +1015,11 @@ LOGGER_GOROUTINE_ID=false
 LOGGER_COMBINE_REQUEST_AND_RESPONSE=false
 LOGGER_LEVEL='Info'
 LOGGER_IGNORE_CLI_MODE=false
+LOGGER_STRICT_JSON=true
+LOGGER_ESCAPE_TEXT=true
+LOGGER_INDENT_MULTI_LINE=false
+LOGGER_DURATION_FORMAT='seconds'
+LOGGER_FATAL_FLUSH_TIMEOUT=5
 `
 	if actual != expected {
 		t.Errorf("unexpected:\n%s\nvs:\n%s\n", actual, expected)
@@ -0,0 +1,161 @@
+// Copyright 2026 Fortio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Counters of the logged entries per level (and optionally per call site), dropped, sampled and write errors.
+
+package log // import "fortio.org/log"
+
+import (
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	levelCounts      [256]int64 // indexed by level + 128 (levels are int8, custom ones can be negative).
+	droppedCount     int64
+	sampledCount     int64
+	writeErrorCount  int64
+	callSiteCounting int32
+	callSiteCounts   sync.Map // "package/file:line" -> *int64
+	callSiteKeys     sync.Map // pc -> "package/file:line"
+)
+
+// Stats are the logger counters, see GetStats.
+type Stats struct {
+	// Number of entries logged per level name (lowercase, e.g. "error"), "none" for Printf.
+	Levels map[string]int64 `json:"levels"`
+	// Number of entries per call site, only if enabled with SetCallSiteCounting. The keys are the package
+	// path, file name and line, e.g. "fortio.org/log/logger.go:42", so same named files don't collide.
+	CallSites map[string]int64 `json:"call_sites,omitempty"`
+	// Entries lost: request log buffer full (see HTTPLogOptions.RequestLogBufferSize), slow tail subscribers.
+	Dropped int64 `json:"dropped"`
+	// Entries skipped by sampling (LogEveryN, LogFirstN and LogEveryDuration).
+	Sampled int64 `json:"sampled"`
+	// Errors writing to the output.
+	WriteErrors int64 `json:"write_errors"`
+}
+
+// levelName returns the lowercase name of the level used in the stats and metrics.
+func levelName(lvl Level) string {
+	if lvl == NoLevel {
+		return "none"
+	}
+	return strings.ToLower(lvl.String())
+}
+
+// LevelCount returns the number of entries logged so far at the given level (request buffered
+// entries, see RequestLogBufferSize, are only counted when they are flushed).
+func LevelCount(lvl Level) int64 {
+	return atomic.LoadInt64(&levelCounts[int(lvl)+128])
+}
+
+// SetCallSiteCounting enables (or disables) counting the entries per call site (file and line). This requires
+// finding the caller of each entry even when not logging file and line, so it has a cost.
+func SetCallSiteCounting(enabled bool) {
+	var v int32
+	if enabled {
+		v = 1
+	}
+	atomic.StoreInt32(&callSiteCounting, v)
+}
+
+func countingCallSites() bool {
+	return atomic.LoadInt32(&callSiteCounting) != 0
+}
+
+// GetStats returns a snapshot of the counters.
+func GetStats() *Stats {
+	st := &Stats{
+		Levels:      make(map[string]int64, int(NoLevel)+1+len(getExtraLevels())),
+		Dropped:     atomic.LoadInt64(&droppedCount),
+		Sampled:     atomic.LoadInt64(&sampledCount),
+		WriteErrors: atomic.LoadInt64(&writeErrorCount),
+	}
+	for l := Debug; l <= NoLevel; l++ {
+		st.Levels[levelName(l)] = LevelCount(l)
+	}
+	for l := range getExtraLevels() {
+		st.Levels[levelName(l)] = LevelCount(l)
+	}
+	callSiteCounts.Range(func(k, v any) bool {
+		if st.CallSites == nil {
+			st.CallSites = make(map[string]int64)
+		}
+		st.CallSites[k.(string)] = atomic.LoadInt64(v.(*int64))
+		return true
+	})
+	return st
+}
+
+// ResetStats sets all the counters back to 0 (and forgets the call sites).
+func ResetStats() {
+	for l := range levelCounts {
+		atomic.StoreInt64(&levelCounts[l], 0)
+	}
+	atomic.StoreInt64(&droppedCount, 0)
+	atomic.StoreInt64(&sampledCount, 0)
+	atomic.StoreInt64(&writeErrorCount, 0)
+	callSiteCounts.Range(func(k, _ any) bool {
+		callSiteCounts.Delete(k)
+		return true
+	})
+}
+
+func countLevel(lvl Level) {
+	atomic.AddInt64(&levelCounts[int(lvl)+128], 1)
+}
+
+func countCallSite(key string) {
+	v, found := callSiteCounts.Load(key)
+	if !found {
+		v, _ = callSiteCounts.LoadOrStore(key, new(int64))
+	}
+	atomic.AddInt64(v.(*int64), 1)
+}
+
+// countCaller counts the call site skip frames above the caller of countCaller.
+func countCaller(skip int) {
+	pc, file, line, _ := runtime.Caller(skip + 1)
+	countCallSite(callSiteKey(pc, file, line))
+}
+
+// callSiteKey returns the (cached) "package/file:line" key for the call site at pc, the package path
+// coming from the function name (e.g. "fortio.org/log.S" for fortio.org/log).
+func callSiteKey(pc uintptr, file string, line int) string {
+	if key, found := callSiteKeys.Load(pc); found {
+		return key.(string)
+	}
+	file = file[strings.LastIndex(file, "/")+1:]
+	if fn := runtime.FuncForPC(pc); fn != nil {
+		name := fn.Name()
+		slash := strings.LastIndex(name, "/") + 1
+		if dot := strings.Index(name[slash:], "."); dot > 0 {
+			file = name[:slash+dot] + "/" + file
+		}
+	}
+	key := file + ":" + strconv.Itoa(line)
+	callSiteKeys.Store(pc, key)
+	return key
+}
+
+func countDropped(n int) {
+	atomic.AddInt64(&droppedCount, int64(n))
+}
+
+func countWriteError() {
+	atomic.AddInt64(&writeErrorCount, 1)
+}
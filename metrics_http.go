@@ -0,0 +1,80 @@
+// Copyright 2026 Fortio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !no_http && !no_net
+
+// Export of the counters through expvar and as Prometheus text metrics.
+
+package log // import "fortio.org/log"
+
+import (
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// PublishExpvar publishes the Stats as the expvar variable of the given name (e.g. "log"), visible on
+// /debug/vars. Like expvar.Publish, it panics if the name is already used.
+func PublishExpvar(name string) {
+	expvar.Publish(name, expvar.Func(func() any { return GetStats() }))
+}
+
+// promLabelEscape escapes a Prometheus label value.
+func promLabelEscape(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// writePromCounter writes a counter metric with its help and type, values keyed by label value
+// (sorted), or the single value for key "" if label is empty.
+func writePromCounter(w io.Writer, name, help, label string, values map[string]int64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+	if label == "" {
+		fmt.Fprintf(w, "%s %d\n", name, values[""])
+		return
+	}
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s{%s=\"%s\"} %d\n", name, label, promLabelEscape(k), values[k])
+	}
+}
+
+// WritePrometheusMetrics writes the counters in the Prometheus text exposition format.
+func WritePrometheusMetrics(w io.Writer) {
+	st := GetStats()
+	writePromCounter(w, "log_entries_total", "Number of log entries by level.", "level", st.Levels)
+	if len(st.CallSites) > 0 {
+		writePromCounter(w, "log_call_site_entries_total", "Number of log entries by call site.", "site", st.CallSites)
+	}
+	writePromCounter(w, "log_dropped_total", "Number of dropped log entries.", "", map[string]int64{"": st.Dropped})
+	writePromCounter(w, "log_sampled_total", "Number of log entries skipped by sampling.", "",
+		map[string]int64{"": st.Sampled})
+	writePromCounter(w, "log_write_errors_total", "Number of errors writing log entries.", "",
+		map[string]int64{"": st.WriteErrors})
+}
+
+// MetricsHandler returns a http.Handler serving the counters in the Prometheus text format
+// (to mount for instance on /metrics, or call WritePrometheusMetrics from an existing metrics handler).
+func MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		WritePrometheusMetrics(w)
+	})
+}
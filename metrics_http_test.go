@@ -0,0 +1,80 @@
+// Copyright 2026 Fortio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !no_http && !no_net
+
+package log // import "fortio.org/log"
+
+import (
+	"bytes"
+	"encoding/json"
+	"expvar"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	SetLogLevelQuiet(Info)
+	Config.LogFileAndLine = false
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	ResetStats()
+	Errf("one error")
+	countCallSite(`example.com/pkg/we"ird.go:42`)
+	countDropped(2)
+	w := httptest.NewRecorder()
+	MetricsHandler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(w.Result().Body)
+	expected := `# HELP log_entries_total Number of log entries by level.
+# TYPE log_entries_total counter
+log_entries_total{level="critical"} 0
+log_entries_total{level="debug"} 0
+log_entries_total{level="error"} 1
+log_entries_total{level="fatal"} 0
+log_entries_total{level="info"} 0
+log_entries_total{level="none"} 0
+log_entries_total{level="trace"} 0
+log_entries_total{level="verbose"} 0
+log_entries_total{level="warning"} 0
+# HELP log_call_site_entries_total Number of log entries by call site.
+# TYPE log_call_site_entries_total counter
+log_call_site_entries_total{site="example.com/pkg/we\"ird.go:42"} 1
+# HELP log_dropped_total Number of dropped log entries.
+# TYPE log_dropped_total counter
+log_dropped_total 2
+# HELP log_sampled_total Number of log entries skipped by sampling.
+# TYPE log_sampled_total counter
+log_sampled_total 0
+# HELP log_write_errors_total Number of errors writing log entries.
+# TYPE log_write_errors_total counter
+log_write_errors_total 0
+`
+	if string(body) != expected {
+		t.Errorf("unexpected:\n%s\nvs:\n%s\n", body, expected)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("unexpected content type %q", ct)
+	}
+	PublishExpvar("log_test")
+	var st Stats
+	if err := json.Unmarshal([]byte(expvar.Get("log_test").String()), &st); err != nil {
+		t.Fatalf("unexpected expvar error %v", err)
+	}
+	if st.Levels["error"] != 1 || st.Dropped != 2 || st.CallSites[`example.com/pkg/we"ird.go:42`] != 1 {
+		t.Errorf("unexpected expvar stats %+v", st)
+	}
+	ResetStats()
+}
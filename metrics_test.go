@@ -0,0 +1,126 @@
+// Copyright 2026 Fortio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log // import "fortio.org/log"
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type errWriter struct{}
+
+func (errWriter) Write(_ []byte) (int, error) {
+	return 0, errors.New("write error")
+}
+
+func TestStats(t *testing.T) {
+	SetLogLevelQuiet(Info)
+	Config.LogFileAndLine = false
+	Config.JSON = true
+	Config.NoTimestamp = false
+	Config.GoroutineID = false
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	ResetStats()
+	SetCallSiteCounting(true)
+	for i := 0; i < 3; i++ {
+		Errf("error %d", i) // fast path.
+	}
+	Config.NoTimestamp = true
+	Warnf("warning %d", 1)
+	S(Warning, "warning", Int("i", 2))
+	Debugf("not logged")
+	Printf("no level")
+	SetCallSiteCounting(false)
+	S(Info, "not counted by call site")
+	if c := LevelCount(Error); c != 3 {
+		t.Errorf("unexpected error count %d", c)
+	}
+	st := GetStats()
+	expected := map[string]int64{
+		"trace": 0, "debug": 0, "verbose": 0, "info": 1, "warning": 2, "error": 3, "critical": 0, "fatal": 0, "none": 1,
+	}
+	for k, v := range expected {
+		if st.Levels[k] != v {
+			t.Errorf("unexpected %s count %d instead of %d: %v", k, st.Levels[k], v, st.Levels)
+		}
+	}
+	if len(st.Levels) != len(expected) {
+		t.Errorf("unexpected levels %v", st.Levels)
+	}
+	sites := 0
+	for site, n := range st.CallSites {
+		if !strings.HasPrefix(site, "fortio.org/log/metrics_test.go:") {
+			t.Errorf("unexpected call site %s", site)
+		}
+		sites++
+		if n != 1 && n != 3 {
+			t.Errorf("unexpected count %d for %s", n, site)
+		}
+	}
+	if sites != 3 {
+		t.Errorf("unexpected call sites %v", st.CallSites)
+	}
+	// Write errors, in both json and text modes, and drops.
+	SetOutput(errWriter{})
+	Infof("fails")
+	Config.JSON = false
+	S(Info, "fails too")
+	Config.JSON = true
+	SetOutput(&buf)
+	b := newLogBuffer(10)
+	b.write(Info, "this is too long for the buffer\n")
+	b.finish(false)
+	st = GetStats()
+	if st.WriteErrors != 2 || st.Dropped != 1 || st.Sampled != 0 {
+		t.Errorf("unexpected stats %+v", st)
+	}
+	ResetStats()
+	st = GetStats()
+	if st.WriteErrors != 0 || st.Levels["error"] != 0 || st.CallSites != nil {
+		t.Errorf("unexpected stats after reset %+v", st)
+	}
+	Config.GoroutineID = true
+}
+
+func TestBufferedLevelCounts(t *testing.T) {
+	SetLogLevelQuiet(Info)
+	Config.JSON = true
+	Config.GoroutineID = false
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	ResetStats()
+	discarded := newLogBuffer(1000)
+	SCtx(withLogState(context.Background(), &requestLogState{buf: discarded}), Debug, "discarded")
+	discarded.finish(false)
+	if c := LevelCount(Debug); c != 0 || buf.Len() != 0 {
+		t.Errorf("discarded entries shouldn't be counted, got %d (output %q)", c, buf.String())
+	}
+	flushed := newLogBuffer(1000)
+	ctx := withLogState(context.Background(), &requestLogState{buf: flushed})
+	SCtx(ctx, Debug, "flushed")
+	if c := LevelCount(Debug); c != 0 {
+		t.Errorf("buffered entries shouldn't be counted before being flushed, got %d", c)
+	}
+	flushed.finish(true)
+	SCtx(ctx, Debug, "after flush")
+	if c := LevelCount(Debug); c != 2 || strings.Count(buf.String(), "\n") != 2 {
+		t.Errorf("expected 2 debug entries counted and written, got %d (output %q)", c, buf.String())
+	}
+	Config.GoroutineID = true
+}
@@ -56,7 +56,7 @@ func mapToString(s map[string]interface{}) string {
 		if i != 0 {
 			buf.WriteString(",")
 		}
-		buf.WriteString(fmt.Sprintf("%q", k))
+		buf.WriteString(jsonString(k))
 		buf.WriteString(":")
 		vv := ValueType[interface{}]{Val: s[k]}
 		buf.WriteString(vv.String())
@@ -65,27 +65,30 @@ func mapToString(s map[string]interface{}) string {
 	return buf.String()
 }
 
-const nullString = "null"
-
 func (v ValueType[T]) String() string {
-	// if the type is numeric, use Sprint(v.val) otherwise use Sprintf("%q", v.Val) to quote it.
+	// if the type is numeric, use Sprint(v.val) otherwise quote it (see jsonString()).
 	switch s := any(v.Val).(type) {
-	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64,
-		float32, float64:
+	case LogValuer:
+		return ValueType[any]{Val: resolveLogValuer(s)}.String()
+	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
 		return fmt.Sprint(s)
+	case float32:
+		return jsonFloat(s)
+	case float64:
+		return jsonFloat(s)
 	case string:
-		return fmt.Sprintf("%q", s)
+		return jsonString(s)
 	case *string:
 		if s == nil {
 			return nullString
 		}
-		return fmt.Sprintf("%q", *s)
+		return jsonString(*s)
 	case []interface{}:
 		return arrayToString(s)
 	case map[string]interface{}:
 		return mapToString(s)
 	case error:
-		return fmt.Sprintf("%q", s.Error()) // nil errors handled in case nil below
+		return jsonString(s.Error()) // nil errors handled in case nil below
 	case nil:
 		return nullString // nil interface{} like `var err error` (but not nil *string etc.)
 	default:
@@ -98,6 +101,6 @@ func (v ValueType[T]) String() string {
 			vv := ValueType[interface{}]{Val: val.Elem().Interface()}
 			return vv.String()
 		}
-		return fmt.Sprintf("%q", fmt.Sprintf("%+v", v.Val))
+		return jsonString(fmt.Sprintf("%+v", v.Val))
 	}
 }
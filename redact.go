@@ -0,0 +1,228 @@
+// Copyright 2026 Fortio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Secret and PII redaction of messages and attributes (including http headers).
+
+package log // import "fortio.org/log"
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// Redacted is what RedactReplace replaces sensitive values with.
+const Redacted = "[REDACTED]"
+
+// RedactStrategy transforms a sensitive value into what gets logged instead.
+type RedactStrategy func(value string) string
+
+// RedactReplace replaces the whole value by [Redacted].
+func RedactReplace(string) string {
+	return Redacted
+}
+
+// RedactMask keeps the last 4 characters of the value and replaces the others by '*'.
+// Values of 8 characters or less are entirely masked.
+func RedactMask(value string) string {
+	runes := []rune(value)
+	keep := 4
+	if len(runes) <= 8 {
+		keep = 0
+	}
+	return strings.Repeat("*", len(runes)-keep) + string(runes[len(runes)-keep:])
+}
+
+// Scrubber redacts the parts of messages and string values matching Pattern.
+type Scrubber struct {
+	Pattern *regexp.Regexp
+	// Strategy applied to each match, RedactReplace if nil.
+	Strategy RedactStrategy
+	// Optional additional check of a match (e.g. Luhn checksum for card numbers), matches
+	// for which it returns false are left as is.
+	Validate func(match string) bool
+}
+
+// Redactor configures the redaction applied to all entries (messages, S() attributes
+// and LogRequest/LogAndCall headers). See [SetRedactor] and [DefaultRedactor].
+type Redactor struct {
+	// Attribute or http header names whose whole value is redacted (case-insensitive).
+	// For dotted keys (e.g "header.authorization" or groups) the last component also matches.
+	Keys []string
+	// Strategy applied to the values of Keys, RedactReplace if nil.
+	KeyStrategy RedactStrategy
+	// Applied to messages and to string (and nested) attribute values.
+	Scrubbers []Scrubber
+	keys      map[string]struct{}
+	// scrubFn is scrubAll, set by SetRedactor. Only calling it through this field keeps the regexp
+	// code out of the binaries that never set a Redactor.
+	scrubFn func(s string, escape bool) string
+}
+
+// DefaultRedactor returns a Redactor for the usual credential headers and attribute names
+// (authorization, cookie, api keys, passwords, tokens...) and scrubbers for bearer/basic tokens,
+// JWTs, emails and (Luhn valid) card numbers.
+func DefaultRedactor() *Redactor {
+	return &Redactor{
+		Keys: []string{
+			"authorization", "proxy-authorization", "cookie", "set-cookie",
+			"x-api-key", "api-key", "api_key", "apikey", "x-auth-token", "x-csrf-token",
+			"password", "passwd", "secret", "client_secret", "token", "access_token", "refresh_token",
+		},
+		Scrubbers: []Scrubber{
+			{Pattern: regexp.MustCompile(`(?i)\b(?:bearer|basic)\s+[A-Za-z0-9._~+/=-]+`)},
+			{Pattern: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)},
+			{Pattern: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)},
+			{Pattern: regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`), Strategy: RedactMask, Validate: luhnValid},
+		},
+	}
+}
+
+// luhnValid returns true if the digits of s pass the Luhn checksum (used by credit card numbers).
+func luhnValid(s string) bool {
+	sum := 0
+	double := false
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+var redactor atomic.Value // *Redactor
+
+// SetRedactor installs r for all subsequent logging, nil disables redaction (the default).
+// r must not be modified after this call (call SetRedactor again with a new one instead).
+func SetRedactor(r *Redactor) {
+	if r != nil {
+		r.keys = make(map[string]struct{}, len(r.Keys))
+		for _, k := range r.Keys {
+			r.keys[strings.ToLower(k)] = struct{}{}
+		}
+		r.scrubFn = r.scrubAll
+	}
+	redactor.Store(&r) // pointer to pointer as atomic.Value can't store nil.
+}
+
+// GetRedactor returns the current Redactor (nil if redaction is off).
+func GetRedactor() *Redactor {
+	r, _ := redactor.Load().(**Redactor)
+	if r == nil {
+		return nil
+	}
+	return *r
+}
+
+// IsSensitiveKey returns true if values for the attribute or header name key are redacted.
+func (r *Redactor) IsSensitiveKey(key string) bool {
+	if r == nil || len(r.keys) == 0 {
+		return false
+	}
+	key = strings.ToLower(key)
+	if _, found := r.keys[key]; found {
+		return true
+	}
+	if idx := strings.LastIndex(key, "."); idx >= 0 {
+		_, found := r.keys[key[idx+1:]]
+		return found
+	}
+	return false
+}
+
+// RedactKeyValue returns what to log instead of value for a sensitive key.
+func (r *Redactor) RedactKeyValue(value string) string {
+	if r.KeyStrategy == nil {
+		return RedactReplace(value)
+	}
+	return r.KeyStrategy(value)
+}
+
+// Scrub applies the scrubbers to s.
+func (r *Redactor) Scrub(s string) string {
+	if r == nil {
+		return s
+	}
+	return r.scrubAll(s, false)
+}
+
+// scrub applies the scrubbers of a Redactor set by SetRedactor.
+func (r *Redactor) scrub(s string, escape bool) string {
+	if r == nil || r.scrubFn == nil {
+		return s
+	}
+	return r.scrubFn(s, escape)
+}
+
+// scrubAll applies the scrubbers, if escape is true the replacements are escaped to be inside JSON strings.
+func (r *Redactor) scrubAll(s string, escape bool) string {
+	for _, sc := range r.Scrubbers {
+		if sc.Pattern == nil {
+			continue
+		}
+		s = sc.Pattern.ReplaceAllStringFunc(s, func(match string) string {
+			if sc.Validate != nil && !sc.Validate(match) {
+				return match
+			}
+			repl := RedactReplace(match)
+			if sc.Strategy != nil {
+				repl = sc.Strategy(match)
+			}
+			if escape {
+				q := jsonString(repl)
+				repl = q[1 : len(q)-1]
+			}
+			return repl
+		})
+	}
+	return s
+}
+
+// redactMsg scrubs a message if redaction is on.
+func redactMsg(msg string) string {
+	return GetRedactor().scrub(msg, false)
+}
+
+// redactValue returns the (encoded) value to log for key, redacted if needed.
+// Quoted strings are decoded first so the key strategy and scrubbers see the raw value
+// and not its escaped form, then re-quoted. Objects and arrays are scrubbed as encoded.
+func (r *Redactor) redactValue(key, value string) string {
+	raw, isString := value, false
+	if value != "" && value[0] == '"' {
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			raw, isString = unquoted, true
+		}
+	}
+	if r.IsSensitiveKey(key) {
+		return jsonString(r.RedactKeyValue(raw))
+	}
+	if isString {
+		return jsonString(r.scrub(raw, false))
+	}
+	if value == "" || (value[0] != '{' && value[0] != '[') {
+		return value // numbers, booleans, null, durations in text mode, etc.
+	}
+	return r.scrub(value, true)
+}
@@ -0,0 +1,63 @@
+// Copyright 2026 Fortio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !no_crypto
+
+// Keyed hash redaction strategy, in its own file so the crypto packages can be left out of
+// the small binaries with the no_crypto tag.
+
+package log // import "fortio.org/log"
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync/atomic"
+)
+
+var redactHashKey atomic.Value // []byte
+
+// SetRedactHashKey sets the HMAC key used by RedactHash. By default (or if key is empty) a random key
+// is used, which is different for each process: set the same secret key in all the processes whose logs
+// need to be correlated.
+func SetRedactHashKey(key []byte) {
+	if len(key) == 0 {
+		key = randomKey()
+	}
+	redactHashKey.Store(append([]byte(nil), key...))
+}
+
+func randomKey() []byte {
+	key := make([]byte, 32)
+	_, _ = rand.Read(key) // doesn't fail on supported platforms.
+	return key
+}
+
+func getRedactHashKey() []byte {
+	if key, ok := redactHashKey.Load().([]byte); ok {
+		return key
+	}
+	redactHashKey.CompareAndSwap(nil, randomKey())
+	return redactHashKey.Load().([]byte)
+}
+
+// RedactHash replaces the value by a keyed hash ("hmac:" followed by the 32 hex digits of the
+// truncated HMAC-SHA256) so identical values can still be correlated across entries without being
+// revealed or brute forced from the logs. See [SetRedactHashKey].
+func RedactHash(value string) string {
+	mac := hmac.New(sha256.New, getRedactHashKey())
+	mac.Write([]byte(value))
+	return "hmac:" + hex.EncodeToString(mac.Sum(nil)[:16])
+}
@@ -0,0 +1,41 @@
+// Copyright 2026 Fortio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !no_crypto
+
+package log // import "fortio.org/log"
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestRedactHash(t *testing.T) {
+	h := RedactHash("secret")
+	if h != RedactHash("secret") || h == RedactHash("secret2") || !strings.HasPrefix(h, "hmac:") || len(h) != 5+32 {
+		t.Errorf("unexpected hash %q", h)
+	}
+	key := []byte("shared key")
+	SetRedactHashKey(key)
+	defer SetRedactHashKey(nil)
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("secret"))
+	expected := "hmac:" + hex.EncodeToString(mac.Sum(nil)[:16])
+	if actual := RedactHash("secret"); actual != expected || actual == h {
+		t.Errorf("unexpected hash with key %q vs %q (random key %q)", actual, expected, h)
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright 2026 Fortio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log // import "fortio.org/log"
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestRedactStrategies(t *testing.T) {
+	if RedactReplace("foo") != Redacted {
+		t.Errorf("unexpected %q", RedactReplace("foo"))
+	}
+	for _, tst := range []struct {
+		in, expected string
+	}{
+		{"", ""},
+		{"12345678", "********"},
+		{"4111 1111 1111 1111", "***************1111"},
+		{"éèàçùéèàçù", "******èàçù"}, // runes, not bytes.
+	} {
+		if actual := RedactMask(tst.in); actual != tst.expected {
+			t.Errorf("for %q got %q expected %q", tst.in, actual, tst.expected)
+		}
+	}
+	if !luhnValid("4111 1111 1111 1111") || luhnValid("4111 1111 1111 1112") {
+		t.Errorf("luhn check failed")
+	}
+}
+
+func TestRedactor(t *testing.T) {
+	SetLogLevelQuiet(Info)
+	Config.LogFileAndLine = false
+	Config.JSON = true
+	Config.NoTimestamp = true
+	Config.GoroutineID = false
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	r := DefaultRedactor()
+	r.KeyStrategy = RedactMask
+	SetRedactor(r)
+	defer SetRedactor(nil)
+	if GetRedactor() != r {
+		t.Errorf("expected GetRedactor() to return the set redactor")
+	}
+	attrs := []KeyVal{
+		Str("Password", "hunter2"),
+		Str("user", "bob@example.com"),
+		Str("header.authorization", "Bearer abc.def"),
+		Int("card", 42),
+		Str("note", "card 4111-1111-1111-1111 and 1234-5678-9012-3456 not a card"),
+		Group("db", Str("host", "x"), Str("secret", "s3"), Group("token", Int("n", 1))),
+		Any("list", []any{"Authorization: Basic dXNlcjpwYXNz", 3}),
+	}
+	S(Info, "login for bob@example.com", attrs...)
+	Infof("infof jane@example.org")
+	Infof("infof %s", "with Bearer xyz")
+	actual := buf.String()
+	expected := `{"level":"info","msg":"login for [REDACTED]","Password":"*******","user":"[REDACTED]",` +
+		`"header.authorization":"**********.def","card":42,` +
+		`"note":"card ***************1111 and 1234-5678-9012-3456 not a card",` +
+		`"db":{"host":"x","secret":"**","token":"*******"},` +
+		`"list":["Authorization: [REDACTED]",3]}` + "\n" +
+		`{"level":"info","msg":"infof [REDACTED]"}` + "\n" +
+		`{"level":"info","msg":"infof with [REDACTED]"}` + "\n"
+	if actual != expected {
+		t.Errorf("unexpected:\n%s\nvs:\n%s\n", actual, expected)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(actual), "\n") {
+		var m map[string]any
+		if err := json.Unmarshal([]byte(line), &m); err != nil {
+			t.Errorf("invalid json %q: %v", line, err)
+		}
+	}
+	// text mode
+	buf.Reset()
+	Config.JSON = false
+	Config.LogPrefix = " "
+	SetFlags(0)
+	S(Info, "text", Str("token", "abc"), Group("g", Str("email", "a@b.co"), Str("api_key", "k")))
+	actual = buf.String()
+	expected = `[I] text, token="***", g.email="[REDACTED]", g.api_key="*"` + "\n"
+	if actual != expected {
+		t.Errorf("unexpected:\n%s\nvs:\n%s\n", actual, expected)
+	}
+	// Replacement needing escaping in JSON
+	buf.Reset()
+	Config.JSON = true
+	SetRedactor(&Redactor{Keys: []string{"k"}, KeyStrategy: func(string) string { return "a\"b" }})
+	S(Info, "escaping", Str("K", "v"))
+	actual = buf.String()
+	expected = `{"level":"info","msg":"escaping","K":"a\"b"}` + "\n"
+	if actual != expected {
+		t.Errorf("unexpected:\n%s\nvs:\n%s\n", actual, expected)
+	}
+	// Strategies and scrubbers see the raw values, not their JSON escaped form.
+	buf.Reset()
+	SetRedactor(&Redactor{
+		Keys:        []string{"k"},
+		KeyStrategy: func(v string) string { return strconv.Itoa(len(v)) + ":" + v },
+		Scrubbers:   []Scrubber{{Pattern: regexp.MustCompile(`^a"b\nc$`)}},
+	})
+	S(Info, "raw", Str("k", "a\"b\nc"), Str("other", "a\"b\nc"))
+	actual = buf.String()
+	expected = `{"level":"info","msg":"raw","k":"5:a\"b\nc","other":"[REDACTED]"}` + "\n"
+	if actual != expected {
+		t.Errorf("unexpected:\n%s\nvs:\n%s\n", actual, expected)
+	}
+	Config.GoroutineID = true
+}
@@ -0,0 +1,123 @@
+// Copyright 2026 Fortio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// glog style V() verbosity and per call site sampling (LogEveryN, LogEveryDuration, LogFirstN).
+
+package log // import "fortio.org/log"
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Verbosity is the level for glog style V(n) logging, see V().
+type Verbosity Level
+
+// V returns the verbosity for glog style V(n) logging, mapped to the levels: V(0) is Info, V(1) Verbose,
+// V(2) Debug and V(3) and more Trace. e.g.
+//
+//	log.V(2).Infof("details: %v", x) // logged at Debug level if Debug is enabled.
+func V(n int) Verbosity {
+	switch {
+	case n <= 0:
+		return Verbosity(Info)
+	case n == 1:
+		return Verbosity(Verbose)
+	case n == 2:
+		return Verbosity(Debug)
+	default:
+		return Verbosity(Trace)
+	}
+}
+
+// Enabled returns true if the verbosity's level is logged, to guard expensive code.
+func (v Verbosity) Enabled() bool {
+	return Log(Level(v))
+}
+
+// Infof logs at the verbosity's level (if enabled), for glog compatibility.
+func (v Verbosity) Infof(format string, rest ...any) {
+	logPrintf(Level(v), format, rest...)
+}
+
+// S logs the structured entry at the verbosity's level (if enabled).
+func (v Verbosity) S(msg string, attrs ...KeyVal) {
+	s(Level(v), Config.LogFileAndLine, Config.JSON, msg, attrs...)
+}
+
+// callSiteState is the per call site counter and last logged time of the sampling functions.
+type callSiteState struct {
+	count int64
+	last  int64 // unix nanoseconds of the last logged entry.
+}
+
+var callSiteStates sync.Map // call site pc (uintptr) -> *callSiteState
+
+// callSite returns the state for the caller of the caller of callSite (lock-free once it exists).
+func callSite() *callSiteState {
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:])
+	st, found := callSiteStates.Load(pcs[0])
+	if !found {
+		st, _ = callSiteStates.LoadOrStore(pcs[0], &callSiteState{})
+	}
+	return st.(*callSiteState)
+}
+
+func countSampled() {
+	atomic.AddInt64(&sampledCount, 1)
+}
+
+// LogEveryN logs, like Logf, the 1st, n+1th, 2n+1th... occurrences of this call site (when lvl is enabled).
+func LogEveryN(lvl Level, n int, format string, rest ...any) {
+	if !Log(lvl) {
+		return
+	}
+	count := atomic.AddInt64(&callSite().count, 1)
+	if n > 1 && (count-1)%int64(n) != 0 {
+		countSampled()
+		return
+	}
+	logPrintf(lvl, format, rest...)
+}
+
+// LogFirstN logs, like Logf, only the first n occurrences of this call site (when lvl is enabled).
+func LogFirstN(lvl Level, n int, format string, rest ...any) {
+	if !Log(lvl) {
+		return
+	}
+	if atomic.AddInt64(&callSite().count, 1) > int64(n) {
+		countSampled()
+		return
+	}
+	logPrintf(lvl, format, rest...)
+}
+
+// LogEveryDuration logs, like Logf, the occurrences of this call site at least d apart
+// (when lvl is enabled), e.g. at most once a minute.
+func LogEveryDuration(lvl Level, d time.Duration, format string, rest ...any) {
+	if !Log(lvl) {
+		return
+	}
+	st := callSite()
+	now := time.Now().UnixNano()
+	last := atomic.LoadInt64(&st.last)
+	if (last != 0 && now-last < int64(d)) || !atomic.CompareAndSwapInt64(&st.last, last, now) {
+		countSampled()
+		return
+	}
+	logPrintf(lvl, format, rest...)
+}
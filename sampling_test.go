@@ -0,0 +1,86 @@
+// Copyright 2026 Fortio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log // import "fortio.org/log"
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVAndSampling(t *testing.T) {
+	Config.LogFileAndLine = false
+	Config.JSON = true
+	Config.NoTimestamp = true
+	Config.GoroutineID = false
+	var b bytes.Buffer
+	w := bufio.NewWriter(&b)
+	SetOutput(w)
+	SetLogLevelQuiet(Verbose)
+	ResetStats()
+	if !V(0).Enabled() || !V(1).Enabled() || V(2).Enabled() || V(5).Enabled() {
+		t.Errorf("unexpected V() enabled state")
+	}
+	V(0).Infof("v0 %d", 0)
+	V(1).S("v1", Int("n", 1))
+	V(2).Infof("v2 not logged")
+	for i := 0; i < 7; i++ {
+		LogEveryN(Info, 3, "every 3rd %d", i)
+		LogFirstN(Warning, 2, "first 2 %d", i)
+		LogEveryDuration(Error, time.Hour, "once an hour %d", i)
+		LogEveryN(Debug, 1, "debug not logged %d", i)
+	}
+	// separate call sites have separate counters.
+	LogFirstN(Warning, 2, "other first 2 %d", 0)
+	SetLogLevelQuiet(Info)
+	w.Flush()
+	actual := b.String()
+	expected := `{"level":"info","msg":"v0 0"}
+{"level":"verbose","msg":"v1","n":1}
+{"level":"info","msg":"every 3rd 0"}
+{"level":"warn","msg":"first 2 0"}
+{"level":"err","msg":"once an hour 0"}
+{"level":"warn","msg":"first 2 1"}
+{"level":"info","msg":"every 3rd 3"}
+{"level":"info","msg":"every 3rd 6"}
+{"level":"warn","msg":"other first 2 0"}
+`
+	if actual != expected {
+		t.Errorf("unexpected:\n%s\nvs:\n%s\n", actual, expected)
+	}
+	// 4 skipped by every 3rd, 5 by first 2 and 6 by the duration.
+	if st := GetStats(); st.Sampled != 15 {
+		t.Errorf("unexpected sampled count %d", st.Sampled)
+	}
+	// Caller's file and line are reported.
+	b.Reset()
+	Config.LogFileAndLine = true
+	_, _, line, _ := runtime.Caller(0)
+	LogEveryN(Info, 2, "with file and line")
+	V(0).S("structured with file and line")
+	Config.LogFileAndLine = false
+	w.Flush()
+	for i, l := range strings.Split(strings.TrimSpace(b.String()), "\n") {
+		if !strings.Contains(l, fmt.Sprintf(`"file":"sampling_test.go","line":%d,`, line+1+i)) {
+			t.Errorf("unexpected file/line in %s", l)
+		}
+	}
+	ResetStats()
+	Config.GoroutineID = true
+}
@@ -0,0 +1,294 @@
+// Copyright 2026 Fortio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Sinks: additional destinations receiving every logged entry, and an in memory ring buffer sink.
+
+package log // import "fortio.org/log"
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Entry is a logged entry as passed to the sinks. It must not be modified.
+type Entry struct {
+	Time  time.Time
+	Level Level
+	File  string // only set when logging file and line.
+	Line  int
+	Msg   string // redacted message.
+	// Attributes, for filtering, with their redacted JSON encoded values (groups are JSON objects),
+	// already computed so concurrent StringValue() calls don't modify them.
+	Attrs []KeyVal
+	// The entry as a JSON object (always JSON, with timestamp, whichever the output format is).
+	JSON string
+}
+
+// Sink receives all the logged entries (in addition to the regular output), see AddSink.
+// LogEntry is called synchronously from the logging goroutine so it must be fast and must not log itself.
+type Sink interface {
+	LogEntry(e *Entry)
+}
+
+// registeredSink is a sink and its name (empty for the unnamed ones, see AddNamedSink) and state.
+type registeredSink struct {
+	sink     Sink
+	name     string
+	disabled int32 // atomic, set by SetSinkEnabled.
+}
+
+var (
+	sinksMutex sync.Mutex
+	sinks      atomic.Value // []*registeredSink, copy on write.
+)
+
+// AddSink adds a sink to receive all the subsequently logged entries.
+func AddSink(sink Sink) {
+	sinksMutex.Lock()
+	addSinkLocked(&registeredSink{sink: sink})
+	sinksMutex.Unlock()
+}
+
+// AddNamedSink is AddSink for a sink which is then listed by SinkStates and can be disabled and
+// re-enabled by name, with SetSinkEnabled or through the AdminHandler. The name must be unique.
+func AddNamedSink(name string, sink Sink) error {
+	if name == "" {
+		return errors.New("sink name is required")
+	}
+	sinksMutex.Lock()
+	defer sinksMutex.Unlock()
+	if findSink(name) != nil {
+		return fmt.Errorf("sink %q already added", name)
+	}
+	addSinkLocked(&registeredSink{sink: sink, name: name})
+	return nil
+}
+
+func addSinkLocked(rs *registeredSink) {
+	current := getSinks()
+	updated := make([]*registeredSink, 0, len(current)+1)
+	updated = append(updated, current...)
+	sinks.Store(append(updated, rs))
+}
+
+// RemoveSink removes a sink previously added with AddSink or AddNamedSink.
+func RemoveSink(sink Sink) {
+	sinksMutex.Lock()
+	current := getSinks()
+	updated := make([]*registeredSink, 0, len(current))
+	for _, rs := range current {
+		if rs.sink != sink {
+			updated = append(updated, rs)
+		}
+	}
+	sinks.Store(updated)
+	sinksMutex.Unlock()
+}
+
+func getSinks() []*registeredSink {
+	s, _ := sinks.Load().([]*registeredSink)
+	return s
+}
+
+// findSink returns the named sink, nil if not found.
+func findSink(name string) *registeredSink {
+	for _, rs := range getSinks() {
+		if rs.name == name {
+			return rs
+		}
+	}
+	return nil
+}
+
+// SinkState is the name and state of a sink added with AddNamedSink, see SinkStates.
+type SinkState struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+}
+
+// SinkStates returns the sinks added with AddNamedSink, in the order they were added.
+func SinkStates() []SinkState {
+	var res []SinkState
+	for _, rs := range getSinks() {
+		if rs.name != "" {
+			res = append(res, SinkState{Name: rs.name, Enabled: atomic.LoadInt32(&rs.disabled) == 0})
+		}
+	}
+	return res
+}
+
+// SetSinkEnabled enables or disables (the entries are then not passed to it) the named sink.
+func SetSinkEnabled(name string, enabled bool) error {
+	rs := findSink(name)
+	if name == "" || rs == nil {
+		return fmt.Errorf("unknown sink %q", name)
+	}
+	var disabled int32
+	if !enabled {
+		disabled = 1
+	}
+	atomic.StoreInt32(&rs.disabled, disabled)
+	return nil
+}
+
+// dispatch sends the entry to the sinks, if any. file is empty when not logging the caller's file and line.
+func dispatch(lvl Level, file string, line int, msg string, attrs []KeyVal) {
+	current := getSinks()
+	if len(current) == 0 {
+		return
+	}
+	e := &Entry{Time: time.Now(), Level: lvl, File: file, Line: line, Msg: msg}
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "{\"ts\":%.6f,\"level\":%s,", TimeToTS(e.Time), lvl.jsonName())
+	if file != "" {
+		fmt.Fprintf(&buf, "\"file\":%s,\"line\":%d,", jsonString(file), line)
+	}
+	buf.WriteString("\"msg\":")
+	buf.WriteString(jsonString(msg))
+	if len(attrs) > 0 {
+		// Only the redacted values are shared with the sinks (and hooks), not the original ones.
+		r := GetRedactor()
+		e.Attrs = make([]KeyVal, len(attrs))
+		for i := range attrs {
+			value := redactedJSONValue(r, "", &attrs[i])
+			e.Attrs[i] = KeyVal{Key: attrs[i].Key, StrValue: value, Value: encodedValue(value), Cached: true}
+		}
+		appendAttrs(&buf, ",%s:%s", true, "", e.Attrs, nil)
+	}
+	buf.WriteByte('}')
+	e.JSON = buf.String()
+	for _, rs := range current {
+		if atomic.LoadInt32(&rs.disabled) == 0 {
+			rs.sink.LogEntry(e)
+		}
+	}
+}
+
+// encodedValue is an already JSON encoded (and redacted) value.
+type encodedValue string
+
+func (v encodedValue) String() string {
+	return string(v)
+}
+
+// EntryFilter selects entries: at least MinLevel and having all the Attrs (key and value).
+type EntryFilter struct {
+	// Minimum level, nil (unset, as in the zero EntryFilter) selects all the levels.
+	MinLevel *Level
+	// Attribute values to match, strings unquoted, e.g. {"req_id": "abc", "status": "500"}.
+	// The redacted values are matched, so sensitive attributes (see Redactor) and scrubbed parts never match.
+	Attrs map[string]string
+}
+
+// Match returns true if the entry is selected by the filter.
+func (f *EntryFilter) Match(e *Entry) bool {
+	if f.MinLevel != nil && (e.Level < *f.MinLevel || (e.Level == NoLevel && *f.MinLevel > Info)) {
+		return false
+	}
+	if len(f.Attrs) == 0 {
+		return true
+	}
+	r := GetRedactor()
+	matched := 0
+	for i := range e.Attrs {
+		kv := &e.Attrs[i]
+		expected, found := f.Attrs[kv.Key]
+		if !found || r.IsSensitiveKey(kv.Key) {
+			continue
+		}
+		cp := *kv // StringValue() on a copy, to not write to the shared entry (already cached when from dispatch).
+		value := cp.StringValue()
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		}
+		if value == expected {
+			matched++
+		}
+	}
+	return matched >= len(f.Attrs)
+}
+
+// RingBuffer is a Sink keeping the last N entries in memory, which can be retrieved with Entries()
+// or through the (http) TailHandler.
+type RingBuffer struct {
+	mu          sync.Mutex
+	entries     []*Entry
+	next        int
+	full        bool
+	subscribers map[chan *Entry]struct{}
+}
+
+// NewRingBuffer returns a ring buffer sink of the given size. Call AddSink to start recording.
+func NewRingBuffer(size int) *RingBuffer {
+	if size < 1 {
+		size = 1
+	}
+	return &RingBuffer{entries: make([]*Entry, size), subscribers: make(map[chan *Entry]struct{})}
+}
+
+// LogEntry records the entry (Sink interface).
+func (rb *RingBuffer) LogEntry(e *Entry) {
+	rb.mu.Lock()
+	rb.entries[rb.next] = e
+	rb.next++
+	if rb.next == len(rb.entries) {
+		rb.next = 0
+		rb.full = true
+	}
+	for ch := range rb.subscribers {
+		select {
+		case ch <- e:
+		default: // slow subscriber, drop.
+			countDropped(1)
+		}
+	}
+	rb.mu.Unlock()
+}
+
+// entriesLocked returns the recorded entries, oldest first. Must be called with the lock held.
+func (rb *RingBuffer) entriesLocked() []*Entry {
+	if !rb.full {
+		return append([]*Entry(nil), rb.entries[:rb.next]...)
+	}
+	res := make([]*Entry, 0, len(rb.entries))
+	res = append(res, rb.entries[rb.next:]...)
+	return append(res, rb.entries[:rb.next]...)
+}
+
+// Entries returns the recorded entries, oldest first.
+func (rb *RingBuffer) Entries() []*Entry {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	return rb.entriesLocked()
+}
+
+// subscribe returns the current entries and a channel receiving the new ones (until unsubscribe is called).
+func (rb *RingBuffer) subscribe(bufferSize int) ([]*Entry, chan *Entry) {
+	ch := make(chan *Entry, bufferSize)
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	rb.subscribers[ch] = struct{}{}
+	return rb.entriesLocked(), ch
+}
+
+func (rb *RingBuffer) unsubscribe(ch chan *Entry) {
+	rb.mu.Lock()
+	delete(rb.subscribers, ch)
+	rb.mu.Unlock()
+}
@@ -0,0 +1,191 @@
+// Copyright 2026 Fortio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log // import "fortio.org/log"
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRingBufferSink(t *testing.T) {
+	SetLogLevelQuiet(Info)
+	Config.LogFileAndLine = true
+	Config.JSON = false
+	Config.GoroutineID = false
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	rb := NewRingBuffer(3)
+	AddSink(rb)
+	Infof("first %d", 1) // evicted
+	SetRedactor(DefaultRedactor())
+	S(Warning, "second", Str("req_id", "a"), Str("password", "secret"))
+	SetRedactor(nil)
+	Debugf("not logged")
+	SCtx(WithAttrs(context.Background(), Str("req_id", "b")), Error, "third")
+	Printf("fourth")
+	RemoveSink(rb)
+	Infof("not recorded")
+	entries := rb.Entries()
+	if len(entries) != 3 {
+		t.Fatalf("unexpected entries %v", entries)
+	}
+	for i, e := range entries {
+		e.JSON = e.JSON[strings.Index(e.JSON, `"level"`):] // remove timestamp
+
+		// Printf doesn't log file and line.
+		if i < 2 && (e.File != "sink_test.go" || e.Line == 0) {
+			t.Errorf("unexpected file/line for %d: %s:%d", i, e.File, e.Line)
+		}
+		e.JSON = strings.Replace(e.JSON, `"line":`+strconv.Itoa(e.Line), `"line":0`, 1)
+	}
+	expected := []string{
+		`"level":"warn","file":"sink_test.go","line":0,"msg":"second","req_id":"a","password":"[REDACTED]"}`,
+		`"level":"err","file":"sink_test.go","line":0,"msg":"third","req_id":"b"}`,
+		`"level":"info","msg":"fourth"}`,
+	}
+	for i, e := range entries {
+		if e.JSON != expected[i] {
+			t.Errorf("unexpected %d:\n%s\nvs:\n%s\n", i, e.JSON, expected[i])
+		}
+	}
+	minLevel := func(lvl Level) *Level { return &lvl }
+	f := &EntryFilter{MinLevel: minLevel(Warning), Attrs: map[string]string{"req_id": "a"}}
+	if !f.Match(entries[0]) || f.Match(entries[1]) || f.Match(entries[2]) {
+		t.Errorf("unexpected filter results")
+	}
+	SetRedactor(DefaultRedactor())
+	f = &EntryFilter{Attrs: map[string]string{"password": "secret"}}
+	if f.Match(entries[0]) {
+		t.Errorf("sensitive attributes should not match")
+	}
+	SetRedactor(nil)
+	// The sinks only get the redacted values, even after the redactor is removed.
+	if v := entries[0].Attrs[1].StringValue(); v != `"[REDACTED]"` {
+		t.Errorf("expected redacted attribute value, got %s", v)
+	}
+	f = &EntryFilter{Attrs: map[string]string{"password": "secret"}}
+	if f.Match(entries[0]) {
+		t.Errorf("redacted attributes should not match")
+	}
+	f = &EntryFilter{MinLevel: minLevel(Error)}
+	if !f.Match(entries[1]) || f.Match(entries[2]) {
+		t.Errorf("unexpected level filter results")
+	}
+	f = &EntryFilter{}
+	if !f.Match(&Entry{Level: Trace}) || !f.Match(entries[2]) {
+		t.Errorf("zero filter should match all levels")
+	}
+	f = &EntryFilter{MinLevel: minLevel(Verbose)}
+	if f.Match(&Entry{Level: Trace}) || f.Match(&Entry{Level: Debug}) {
+		t.Errorf("unexpected level filter results for levels below Verbose")
+	}
+	f = &EntryFilter{MinLevel: minLevel(Debug)}
+	if f.Match(&Entry{Level: Trace}) || f.Match(&Entry{Level: -5}) || !f.Match(&Entry{Level: Debug}) {
+		t.Errorf("unexpected level filter results for levels below Debug")
+	}
+	Config.LogFileAndLine = false
+	Config.GoroutineID = true
+}
+
+func TestEntryFilterScrubbedValues(t *testing.T) {
+	SetLogLevelQuiet(Info)
+	SetOutput(&bytes.Buffer{})
+	SetRedactor(DefaultRedactor())
+	defer SetRedactor(nil)
+	var got *Entry
+	remove := OnLevel(Info, func(e *Entry) { got = e })
+	S(Info, "user", Str("email", "john@example.com"), Str("quoted", "a\"b"))
+	remove()
+	if got == nil || got.Attrs[0].StringValue() != `"[REDACTED]"` {
+		t.Fatalf("expected the hook to get the scrubbed value, got %+v", got)
+	}
+	if f := (&EntryFilter{Attrs: map[string]string{"email": "john@example.com"}}); f.Match(got) {
+		t.Errorf("scrubbed value should not match")
+	}
+	if f := (&EntryFilter{Attrs: map[string]string{"quoted": `a"b`}}); !f.Match(got) {
+		t.Errorf("unquoted value should match")
+	}
+}
+
+func TestEntryFilterConcurrentMatch(t *testing.T) {
+	SetLogLevelQuiet(Info)
+	SetOutput(&bytes.Buffer{})
+	SetRedactor(DefaultRedactor())
+	defer SetRedactor(nil)
+	rb := NewRingBuffer(2)
+	AddSink(rb)
+	S(Info, "grouped", Group("g", Str("k", "v")))
+	RemoveSink(rb)
+	e := rb.Entries()[0]
+	f := &EntryFilter{Attrs: map[string]string{"g": `{"k":"v"}`}}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f.Match(e) // must not race (go test -race).
+		}()
+	}
+	wg.Wait()
+}
+
+func TestNamedSinks(t *testing.T) {
+	SetLogLevelQuiet(Info)
+	SetOutput(&bytes.Buffer{})
+	rb := NewRingBuffer(10)
+	if err := AddNamedSink("", rb); err == nil {
+		t.Errorf("expected error for empty sink name")
+	}
+	if err := AddNamedSink("ring", rb); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	defer RemoveSink(rb)
+	if err := AddNamedSink("ring", NewRingBuffer(1)); err == nil {
+		t.Errorf("expected error for duplicate sink name")
+	}
+	unnamed := NewRingBuffer(1)
+	AddSink(unnamed) // not listed.
+	defer RemoveSink(unnamed)
+	states := SinkStates()
+	if len(states) != 1 || states[0] != (SinkState{Name: "ring", Enabled: true}) {
+		t.Errorf("unexpected sink states %+v", states)
+	}
+	Infof("recorded 1")
+	if err := SetSinkEnabled("ring", false); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	Infof("not recorded")
+	if states = SinkStates(); states[0].Enabled {
+		t.Errorf("expected the sink to be disabled: %+v", states)
+	}
+	if err := SetSinkEnabled("ring", true); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	Infof("recorded 2")
+	if err := SetSinkEnabled("foo", true); err == nil {
+		t.Errorf("expected error for unknown sink")
+	}
+	entries := rb.Entries()
+	if len(entries) != 2 || entries[0].Msg != "recorded 1" || entries[1].Msg != "recorded 2" {
+		t.Errorf("unexpected entries %+v", entries)
+	}
+	if len(unnamed.Entries()) != 1 {
+		t.Errorf("unnamed sink should have gotten the last entry")
+	}
+}
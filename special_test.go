@@ -3,7 +3,11 @@ package log
 import (
 	"bufio"
 	"bytes"
+	"encoding/json"
+	"errors"
 	"math"
+	"net"
+	"strings"
 	"testing"
 )
 
@@ -19,14 +23,76 @@ func Test_LogS_JSON_NaN(t *testing.T) {
 	// Start of the actual test
 	value := math.NaN()
 	zero := 0.0
-	S(Verbose, "Test NaN", Any("nan", value), Any("minus-inf", -1.0/zero))
+	S(Verbose, "Test NaN", Any("nan", value), Any("minus-inf", -1.0/zero), Any("inf32", float32(1.0/zero)))
 	_ = w.Flush()
 	actual := b.String()
+	// StrictJSON (default) quotes them so it can be deserialized
+	expected := `{"level":"verbose","msg":"Test NaN","nan":"NaN","minus-inf":"-Inf","inf32":"+Inf"}` + "\n"
+	if actual != expected {
+		t.Errorf("unexpected:\n%s\nvs:\n%s\n", actual, expected)
+	}
+	var m map[string]any
+	if err := json.Unmarshal(b.Bytes(), &m); err != nil {
+		t.Errorf("unexpected error unmarshaling %q: %v", actual, err)
+	}
+	b.Reset()
+	Config.StrictJSON = false
+	S(Verbose, "Test NaN", Any("nan", value), Any("minus-inf", -1.0/zero))
+	_ = w.Flush()
+	actual = b.String()
 	// Note that we serialize that way but can't deserialize with go default json unmarshaller
-	expected := `{"level":"trace","msg":"Test NaN","nan":NaN,"minus-inf":-Inf}` + "\n"
+	expected = `{"level":"verbose","msg":"Test NaN","nan":NaN,"minus-inf":-Inf}` + "\n"
 	if actual != expected {
 		t.Errorf("unexpected:\n%s\nvs:\n%s\n", actual, expected)
 	}
+	Config.StrictJSON = true
+}
+
+func Test_LogS_JSON_Invalid_UTF8(t *testing.T) {
+	var b bytes.Buffer
+	SetLogLevel(LevelByName("Verbose"))
+	Config.LogFileAndLine = true
+	Config.JSON = true
+	Config.NoTimestamp = false
+	Config.GoroutineID = true
+	SetOutput(&b)
+	bad := "a\xffb\x00c\u2028\U0001F600\x1b"
+	S(Info, "msg "+bad, Str("key "+bad, "value "+bad), Any("map", map[string]any{bad: []any{bad, 1.5}}),
+		Group(bad, Any("err", errors.New(bad)), Stringer("ip", net.IPv4(127, 0, 0, 1))))
+	Infof("infof %s", bad)
+	Config.LogFileAndLine = false
+	Config.GoroutineID = false
+	Printf("printf " + bad)
+	Infof("simple " + bad)
+	S(Info, "no attr "+bad)
+	Config.GoroutineID = true
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("unexpected number of lines %d: %q", len(lines), b.String())
+	}
+	fixed := "a\ufffdb\x00c\u2028\U0001F600\x1b"
+	for _, line := range lines {
+		var e JSONEntry
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Errorf("unexpected error unmarshaling %q: %v", line, err)
+		}
+		if !strings.HasSuffix(e.Msg, fixed) {
+			t.Errorf("unexpected msg %q in %q", e.Msg, line)
+		}
+		if e.TS == 0 {
+			t.Errorf("unexpected 0 ts in %q", line)
+		}
+	}
+	var m map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &m); err != nil {
+		t.Fatalf("unexpected error unmarshaling %q: %v", lines[0], err)
+	}
+	if m["key "+fixed] != "value "+fixed {
+		t.Errorf("unexpected key/value in %v", m)
+	}
+	if !strings.Contains(lines[0], `"a\ufffdb\u0000c\u2028`) {
+		t.Errorf("expected escapes in %q", lines[0])
+	}
 }
 
 func Test_LogS_JSON_Array(t *testing.T) {
@@ -42,7 +108,7 @@ func Test_LogS_JSON_Array(t *testing.T) {
 	S(Verbose, "Test Array", Any("arr", []any{"x", 42, "y"}))
 	_ = w.Flush()
 	actual := b.String()
-	expected := `{"level":"trace","msg":"Test Array","arr":["x",42,"y"]}` + "\n"
+	expected := `{"level":"verbose","msg":"Test Array","arr":["x",42,"y"]}` + "\n"
 	if actual != expected {
 		t.Errorf("unexpected:\n%s\nvs:\n%s\n", actual, expected)
 	}
@@ -69,7 +135,7 @@ func Test_LogS_JSON_Map(t *testing.T) {
 	_ = w.Flush()
 	actual := b.String()
 	//nolint:lll // long lines in expected.
-	expected := `{"level":"trace","msg":"Test Map","map":{"number":3.14,"str1":"val 1","subArray":["x",42,"y"]},"in64":0,"bool":true}` +
+	expected := `{"level":"verbose","msg":"Test Map","map":{"number":3.14,"str1":"val 1","subArray":["x",42,"y"]},"in64":0,"bool":true}` +
 		"\n"
 	if actual != expected {
 		t.Errorf("unexpected:\n%s\nvs:\n%s\n", actual, expected)
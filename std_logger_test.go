@@ -0,0 +1,51 @@
+// Copyright 2026 Fortio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !no_http && !no_net
+
+// Tests from outside the package, so the caller found by the std logger interception
+// is really outside of fortio.org/log (as for users).
+package log_test
+
+import (
+	"bufio"
+	"bytes"
+	"log"
+	"runtime"
+	"strconv"
+	"testing"
+
+	flog "fortio.org/log"
+)
+
+func TestInterceptStandardLogger(t *testing.T) {
+	flog.SetLogLevel(flog.Warning)
+	flog.Config.LogFileAndLine = true
+	flog.Config.JSON = false // check that despite this, it'll be json anyway (so it doesn't go infinite loop)
+	flog.Config.NoTimestamp = true
+	var b bytes.Buffer
+	w := bufio.NewWriter(&b)
+	flog.SetOutput(w)
+	flog.Config.GoroutineID = false
+	flog.InterceptStandardLogger(flog.Warning)
+	_, _, line, _ := runtime.Caller(0)
+	log.Printf("\n\na test\n\n") // caller's file and line are found despite going through the std logger.
+	w.Flush()
+	actual := b.String()
+	expected := `{"level":"warn","file":"std_logger_test.go","line":` + strconv.Itoa(line+1) + `,"msg":"a test","src":"std"}` + "\n"
+	if actual != expected {
+		t.Errorf("unexpected:\n%s\nvs:\n%s\n", actual, expected)
+	}
+	flog.Config.GoroutineID = true
+}
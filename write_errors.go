@@ -0,0 +1,167 @@
+// Copyright 2026 Fortio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Handling of the errors writing to the output.
+
+package log // import "fortio.org/log"
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// BrokenPipeExitCode is the exit code used with WriteErrorPolicy.ExitOnBrokenPipe, the same as the shell's
+// for a process killed by SIGPIPE (128+13).
+const BrokenPipeExitCode = 141
+
+// WriteErrorPolicy is what to do when writing an entry to the output fails, see SetWriteErrorPolicy.
+// Write errors are always counted (see Stats.WriteErrors).
+type WriteErrorPolicy struct {
+	// Number of times to retry writing (what wasn't written yet) after a transient error (see IsTransient),
+	// waiting RetryDelay in between. The retries are done with the output lock held, so other goroutines
+	// can't log meanwhile: keep RetryDelay short.
+	Retries    int
+	RetryDelay time.Duration
+	// Decides which errors can be retried, defaults to IsTransientWriteError if nil.
+	IsTransient func(err error) bool
+	// If set, the entries which couldn't be written are written there instead (e.g. os.Stderr).
+	Fallback io.Writer
+	// If set, called with the error and the entry for each failed write (after the retries).
+	// It is called with the output lock held so it must not log, nor keep entry after returning.
+	OnError func(err error, entry []byte)
+	// If true, exits with BrokenPipeExitCode when the output is a closed pipe, e.g. for command line tools
+	// piped to head. The OnFatal hooks are run and the sinks closed first, as for Fatalf, then
+	// Config.FatalExit is called. (Go already does this for writes to the stdout and stderr file
+	// descriptors unless SIGPIPE is handled).
+	ExitOnBrokenPipe bool
+}
+
+var writeErrorPolicy atomic.Value // *WriteErrorPolicy
+
+// SetWriteErrorPolicy changes the write error policy, nil restores the default of only counting the errors.
+func SetWriteErrorPolicy(p *WriteErrorPolicy) {
+	writeErrorPolicy.Store(p)
+}
+
+// GetWriteErrorPolicy returns the current write error policy (nil if none).
+func GetWriteErrorPolicy() *WriteErrorPolicy {
+	p, _ := writeErrorPolicy.Load().(*WriteErrorPolicy)
+	return p
+}
+
+// IsTransientWriteError returns true for errors that may not happen again when retrying: EAGAIN, EINTR
+// and timeouts.
+func IsTransientWriteError(err error) bool {
+	var timeout interface{ Timeout() bool }
+	return errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.EINTR) ||
+		(errors.As(err, &timeout) && timeout.Timeout())
+}
+
+// retryWrite writes entry with write, the rest of it again per the policy if it fails with a transient
+// error, and returns the last error.
+func (p *WriteErrorPolicy) retryWrite(entry []byte, write func(p []byte) (int, error)) error {
+	n, err := write(entry)
+	if err == nil || p == nil {
+		return err
+	}
+	isTransient := p.IsTransient
+	if isTransient == nil {
+		isTransient = IsTransientWriteError
+	}
+	for i := 0; i < p.Retries && isTransient(err); i++ {
+		entry = entry[n:] // only what wasn't written yet, in case of partial write.
+		time.Sleep(p.RetryDelay)
+		n, err = write(entry)
+	}
+	return err
+}
+
+// failed counts and handles, per the policy, the failure to write entry. It returns true if the caller
+// must call brokenPipeExit(), which can't be done with the output lock held.
+func (p *WriteErrorPolicy) failed(err error, entry []byte) (exit bool) {
+	countWriteError()
+	if p == nil {
+		return false
+	}
+	if p.OnError != nil {
+		p.OnError(err, entry)
+	}
+	if p.Fallback != nil {
+		_, _ = p.Fallback.Write(entry) // nothing more we can do.
+	}
+	return p.ExitOnBrokenPipe && errors.Is(err, syscall.EPIPE)
+}
+
+var exitingOnBrokenPipe int32
+
+// brokenPipeExit runs the fatal hooks and exits with BrokenPipeExitCode, once: entries logged
+// meanwhile (e.g. by the hooks) failing too don't exit again.
+func brokenPipeExit() {
+	if !atomic.CompareAndSwapInt32(&exitingOnBrokenPipe, 0, 1) {
+		return
+	}
+	defer atomic.StoreInt32(&exitingOnBrokenPipe, 0) // in case FatalExit returns (tests).
+	runFatalHooks()
+	Config.FatalExit(BrokenPipeExitCode)
+}
+
+// outputWrite writes the entry to w applying the write error policy. Called with jWriter.mutex held,
+// it returns true if the caller must call brokenPipeExit() after releasing it.
+func outputWrite(w io.Writer, entry []byte) (exit bool) {
+	p := GetWriteErrorPolicy()
+	if err := p.retryWrite(entry, w.Write); err != nil {
+		return p.failed(err, entry)
+	}
+	return false
+}
+
+// errBrokenPipeExit is returned by stdWriter to stdPrint so it exits once the standard logger's lock is released.
+var errBrokenPipeExit = errors.New("broken pipe, exiting")
+
+// stdWriter is the standard logger's output, set by SetOutput, so the write error policy also applies
+// to the text entries (written under the standard logger's lock).
+type stdWriter struct {
+	w io.Writer
+}
+
+func (sw stdWriter) Write(entry []byte) (int, error) {
+	if outputWrite(sw.w, entry) {
+		return 0, errBrokenPipeExit
+	}
+	return len(entry), nil // errors were handled per the policy.
+}
+
+// stdPrint is log.Print().
+func stdPrint(v ...any) {
+	msg := fmt.Sprint(v...)
+	err := log.Output(2, msg)
+	if err == nil {
+		return
+	}
+	exit := errors.Is(err, errBrokenPipeExit)
+	if !exit {
+		// The standard logger's output was changed directly (log.SetOutput instead of SetOutput): no retries.
+		jWriter.mutex.Lock()
+		exit = GetWriteErrorPolicy().failed(err, []byte(msg+"\n"))
+		jWriter.mutex.Unlock()
+	}
+	if exit {
+		brokenPipeExit()
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright 2026 Fortio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log // import "fortio.org/log"
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+)
+
+// failingWriter fails the first failures writes with err.
+type failingWriter struct {
+	err      error
+	failures int
+	calls    int
+	buf      bytes.Buffer
+}
+
+func (f *failingWriter) Write(p []byte) (int, error) {
+	f.calls++
+	if f.calls <= f.failures {
+		return 0, f.err
+	}
+	return f.buf.Write(p)
+}
+
+// partialWriter writes at most max bytes per call, failing with EAGAIN when that's less than asked.
+type partialWriter struct {
+	max int
+	buf bytes.Buffer
+}
+
+func (pw *partialWriter) Write(p []byte) (int, error) {
+	if len(p) <= pw.max {
+		return pw.buf.Write(p)
+	}
+	n, _ := pw.buf.Write(p[:pw.max])
+	return n, syscall.EAGAIN
+}
+
+func TestWriteErrorPolicy(t *testing.T) {
+	SetLogLevelQuiet(Info)
+	Config.LogFileAndLine = false
+	Config.JSON = true
+	Config.NoTimestamp = true
+	Config.GoroutineID = false
+	ResetStats()
+	// Transient errors are retried.
+	w := &failingWriter{err: syscall.EAGAIN, failures: 2}
+	SetOutput(w)
+	SetWriteErrorPolicy(&WriteErrorPolicy{Retries: 3})
+	Infof("retried")
+	if w.calls != 3 || w.buf.String() != `{"level":"info","msg":"retried"}`+"\n" || GetStats().WriteErrors != 0 {
+		t.Errorf("unexpected retries %d: %q", w.calls, w.buf.String())
+	}
+	// Partial writes are resumed, in both json and text modes.
+	pw := &partialWriter{max: 10}
+	SetOutput(pw)
+	SetWriteErrorPolicy(&WriteErrorPolicy{Retries: 10})
+	Infof("partially written")
+	Config.JSON = false
+	prevPrefix := Config.LogPrefix
+	Config.LogPrefix = "> "
+	SetFlags(0)
+	Infof("partially written text")
+	Config.JSON = true
+	expected := `{"level":"info","msg":"partially written"}` + "\n" + "[I]> partially written text\n"
+	if pw.buf.String() != expected || GetStats().WriteErrors != 0 {
+		t.Errorf("unexpected:\n%s\nvs:\n%s\n", pw.buf.String(), expected)
+	}
+	// Others aren't and go to the fallback and callback, in both json and text modes.
+	w = &failingWriter{err: errors.New("disk full"), failures: 10}
+	SetOutput(w)
+	var fallback bytes.Buffer
+	var errs []string
+	SetWriteErrorPolicy(&WriteErrorPolicy{
+		Retries:  3,
+		Fallback: &fallback,
+		OnError: func(err error, entry []byte) {
+			errs = append(errs, err.Error()+": "+string(entry))
+		},
+	})
+	S(Warning, "to fallback", Int("i", 1))
+	Config.JSON = false
+	Infof("text to fallback")
+	Config.JSON = true
+	Config.LogPrefix = prevPrefix
+	expected = `{"level":"warn","msg":"to fallback","i":1}` + "\n" + "[I]> text to fallback\n"
+	if w.calls != 2 || fallback.String() != expected {
+		t.Errorf("unexpected:\n%s\nvs:\n%s\n", fallback.String(), expected)
+	}
+	if len(errs) != 2 || errs[0] != `disk full: {"level":"warn","msg":"to fallback","i":1}`+"\n" {
+		t.Errorf("unexpected errors %q", errs)
+	}
+	if st := GetStats(); st.WriteErrors != 2 {
+		t.Errorf("unexpected write errors %d", st.WriteErrors)
+	}
+	// Exit on broken pipe, after the fatal hooks and without the output lock held (logging from
+	// the hooks or FatalExit doesn't deadlock nor exit again).
+	exitCode := 0
+	exits := 0
+	Config.FatalExit = func(code int) {
+		exitCode = code
+		exits++
+		Infof("logging from FatalExit")
+	}
+	hookCalls := 0
+	OnFatal(func() {
+		hookCalls++
+		Infof("logging from a fatal hook")
+	})
+	defer func() {
+		fatalHooksMutex.Lock()
+		fatalHooks = nil
+		fatalHooksMutex.Unlock()
+	}()
+	SetOutput(&failingWriter{err: syscall.EPIPE, failures: 10})
+	SetWriteErrorPolicy(&WriteErrorPolicy{ExitOnBrokenPipe: true})
+	Infof("broken pipe")
+	if exitCode != BrokenPipeExitCode || exits != 1 || hookCalls != 1 {
+		t.Errorf("unexpected exit code %d, exits %d, hook calls %d", exitCode, exits, hookCalls)
+	}
+	Config.FatalExit = os.Exit
+	SetWriteErrorPolicy(nil)
+	if GetWriteErrorPolicy() != nil {
+		t.Errorf("policy should be reset")
+	}
+	SetOutput(os.Stderr)
+	ResetStats()
+	Config.GoroutineID = true
+}